@@ -12,7 +12,7 @@ func main() {
 		panic(err)
 	}
 
-	slog.Info("env parsed successfully", "environment", cfg.Env)
+	slog.Info("env parsed successfully", "environment", cfg.Env, "auth_backend", cfg.AuthBackend)
 
 	api.Run(cfg)
 }
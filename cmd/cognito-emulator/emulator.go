@@ -0,0 +1,467 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	targetPrefix = "AWSCognitoIdentityProviderService."
+	contentType  = "application/x-amz-json-1.1"
+
+	signUpCodeTTL     = 24 * time.Hour
+	minPasswordLength = 8
+	passwordIter      = 10_000
+)
+
+// cognitoError is an error the way Cognito reports it: an exception name in
+// __type and a message.
+type cognitoError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func (e *cognitoError) Error() string {
+	return e.Type + ": " + e.Message
+}
+
+func exception(name, message string) *cognitoError {
+	return &cognitoError{Type: name, Message: message}
+}
+
+type attribute struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+type user struct {
+	sub          string
+	attributes   map[string]string
+	passwordSalt []byte
+	passwordHash []byte
+	confirmed    bool
+	code         string
+	codeExpires  time.Time
+}
+
+type session struct {
+	sub       string
+	originJti string
+	expiresAt time.Time
+}
+
+// emulator answers Cognito Identity Provider requests for one user pool and
+// app client. Users sign up with their email as username and get a random
+// sub as their actual username, like a pool with email as a sign-in alias.
+type emulator struct {
+	poolId       string
+	clientId     string
+	clientSecret string
+	signer       *signer
+	jwksPath     string
+
+	mu       sync.Mutex
+	users    map[string]*user
+	emails   map[string]string
+	sessions map[string]*session
+}
+
+func newEmulator(issuer, poolId, clientId, clientSecret string) (*emulator, error) {
+	signer, err := newSigner(issuer, clientId)
+	if err != nil {
+		return nil, err
+	}
+
+	issuerURL, err := url.Parse(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer %q: %w", issuer, err)
+	}
+
+	return &emulator{
+		poolId:       poolId,
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		signer:       signer,
+		jwksPath:     strings.TrimSuffix(issuerURL.Path, "/") + jwksPath,
+		users:        make(map[string]*user),
+		emails:       make(map[string]string),
+		sessions:     make(map[string]*session),
+	}, nil
+}
+
+func (e *emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == e.jwksPath {
+		e.signer.serveJWKS(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), targetPrefix)
+	var (
+		out any
+		err error
+	)
+	switch op {
+	case "SignUp":
+		out, err = handle(r, e.signUp)
+	case "ConfirmSignUp":
+		out, err = handle(r, e.confirmSignUp)
+	case "InitiateAuth":
+		out, err = handle(r, e.initiateAuth)
+	case "GetUser":
+		out, err = handle(r, e.getUser)
+	default:
+		err = exception("UnknownOperationException", fmt.Sprintf("Operation %q is not supported by the emulator", op))
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if err != nil {
+		var cogErr *cognitoError
+		if !errors.As(err, &cogErr) {
+			cogErr = exception("InternalErrorException", err.Error())
+		}
+		slog.Info("request failed", "op", op, "exception", cogErr.Type, "message", cogErr.Message)
+		w.Header().Set("X-Amzn-Errortype", cogErr.Type)
+		status := http.StatusBadRequest
+		if cogErr.Type == "InternalErrorException" {
+			status = http.StatusInternalServerError
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(cogErr)
+		return
+	}
+	slog.Info("request", "op", op)
+	json.NewEncoder(w).Encode(out)
+}
+
+// handle decodes the input of an operation and runs it.
+func handle[In any](r *http.Request, op func(*In) (any, error)) (any, error) {
+	var in In
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return nil, exception("SerializationException", "Invalid request body")
+	}
+	return op(&in)
+}
+
+type signUpInput struct {
+	ClientId       string      `json:"ClientId"`
+	Username       string      `json:"Username"`
+	Password       string      `json:"Password"`
+	SecretHash     string      `json:"SecretHash"`
+	UserAttributes []attribute `json:"UserAttributes"`
+}
+
+func (e *emulator) signUp(in *signUpInput) (any, error) {
+	if err := e.checkClient(in.ClientId, in.SecretHash, in.Username); err != nil {
+		return nil, err
+	}
+	email := strings.ToLower(strings.TrimSpace(in.Username))
+	if _, err := mail.ParseAddress(email); err != nil {
+		return nil, exception("InvalidParameterException", "Username should be an email.")
+	}
+	if len(in.Password) < minPasswordLength {
+		return nil, exception("InvalidPasswordException", "Password did not conform with policy: Password not long enough")
+	}
+
+	sub, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	code, err := newCode()
+	if err != nil {
+		return nil, err
+	}
+	u := &user{
+		sub:         sub,
+		attributes:  map[string]string{},
+		code:        code,
+		codeExpires: time.Now().Add(signUpCodeTTL),
+	}
+	for _, attr := range in.UserAttributes {
+		u.attributes[attr.Name] = attr.Value
+	}
+	u.attributes["sub"] = sub
+	u.attributes["email"] = email
+	u.attributes["email_verified"] = "false"
+	if err := u.setPassword(in.Password); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, exists := e.emails[email]; exists {
+		return nil, exception("UsernameExistsException", "An account with the given email already exists.")
+	}
+	e.users[sub] = u
+	e.emails[email] = sub
+
+	slog.Info("confirmation code issued", "email", email, "code", code)
+	return map[string]any{
+		"UserConfirmed": false,
+		"UserSub":       sub,
+		"CodeDeliveryDetails": map[string]string{
+			"AttributeName":  "email",
+			"DeliveryMedium": "EMAIL",
+			"Destination":    maskEmail(email),
+		},
+	}, nil
+}
+
+type confirmSignUpInput struct {
+	ClientId         string `json:"ClientId"`
+	Username         string `json:"Username"`
+	ConfirmationCode string `json:"ConfirmationCode"`
+	SecretHash       string `json:"SecretHash"`
+}
+
+func (e *emulator) confirmSignUp(in *confirmSignUpInput) (any, error) {
+	if err := e.checkClient(in.ClientId, in.SecretHash, in.Username); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	u := e.lookup(in.Username)
+	switch {
+	case u == nil:
+		return nil, exception("UserNotFoundException", "Username/client id combination not found.")
+	case u.confirmed:
+		return nil, exception("NotAuthorizedException", "User cannot be confirmed. Current status is CONFIRMED")
+	case subtle.ConstantTimeCompare([]byte(u.code), []byte(in.ConfirmationCode)) != 1:
+		return nil, exception("CodeMismatchException", "Invalid verification code provided, please try again.")
+	case time.Now().After(u.codeExpires):
+		return nil, exception("ExpiredCodeException", "Invalid code provided, please request a code again.")
+	}
+
+	u.confirmed = true
+	u.code = ""
+	u.attributes["email_verified"] = "true"
+	return map[string]any{}, nil
+}
+
+type initiateAuthInput struct {
+	AuthFlow       string            `json:"AuthFlow"`
+	ClientId       string            `json:"ClientId"`
+	AuthParameters map[string]string `json:"AuthParameters"`
+}
+
+func (e *emulator) initiateAuth(in *initiateAuthInput) (any, error) {
+	switch in.AuthFlow {
+	case "USER_PASSWORD_AUTH":
+		return e.passwordAuth(in)
+	case "REFRESH_TOKEN_AUTH", "REFRESH_TOKEN":
+		return e.refreshAuth(in)
+	default:
+		return nil, exception("InvalidParameterException", fmt.Sprintf("Auth flow %s is not supported by the emulator", in.AuthFlow))
+	}
+}
+
+func (e *emulator) passwordAuth(in *initiateAuthInput) (any, error) {
+	username := in.AuthParameters["USERNAME"]
+	if err := e.checkClient(in.ClientId, in.AuthParameters["SECRET_HASH"], username); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	u := e.lookup(username)
+	switch {
+	case u == nil:
+		return nil, exception("UserNotFoundException", "User does not exist.")
+	case !u.checkPassword(in.AuthParameters["PASSWORD"]):
+		return nil, exception("NotAuthorizedException", "Incorrect username or password.")
+	case !u.confirmed:
+		return nil, exception("UserNotConfirmedException", "User is not confirmed.")
+	}
+
+	refreshToken, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	originJti, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	e.sessions[refreshToken] = &session{
+		sub:       u.sub,
+		originJti: originJti,
+		expiresAt: time.Now().Add(refreshTokenTTL),
+	}
+
+	return e.authResult(u, originJti, refreshToken)
+}
+
+func (e *emulator) refreshAuth(in *initiateAuthInput) (any, error) {
+	if in.ClientId != e.clientId {
+		return nil, exception("ResourceNotFoundException", "User pool client "+in.ClientId+" does not exist.")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, ok := e.sessions[in.AuthParameters["REFRESH_TOKEN"]]
+	if !ok {
+		return nil, exception("NotAuthorizedException", "Invalid Refresh Token")
+	}
+	if time.Now().After(s.expiresAt) {
+		return nil, exception("NotAuthorizedException", "Refresh Token has expired")
+	}
+	u := e.users[s.sub]
+	if u == nil {
+		return nil, exception("NotAuthorizedException", "Invalid Refresh Token")
+	}
+	// The secret hash of a refresh is computed from the username Cognito
+	// assigned, which here is the sub.
+	if err := e.checkSecretHash(in.AuthParameters["SECRET_HASH"], u.sub); err != nil {
+		return nil, err
+	}
+
+	return e.authResult(u, s.originJti, "")
+}
+
+func (e *emulator) authResult(u *user, originJti, refreshToken string) (any, error) {
+	now := time.Now()
+	accessToken, err := e.signer.accessToken(u, originJti, now)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := e.signer.idToken(u, originJti, now)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]any{
+		"AccessToken": accessToken,
+		"IdToken":     idToken,
+		"ExpiresIn":   int(accessTokenTTL.Seconds()),
+		"TokenType":   "Bearer",
+	}
+	if refreshToken != "" {
+		result["RefreshToken"] = refreshToken
+	}
+	return map[string]any{
+		"AuthenticationResult": result,
+		"ChallengeParameters":  map[string]string{},
+	}, nil
+}
+
+type getUserInput struct {
+	AccessToken string `json:"AccessToken"`
+}
+
+func (e *emulator) getUser(in *getUserInput) (any, error) {
+	claims, err := e.signer.parseAccessToken(in.AccessToken)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, exception("NotAuthorizedException", "Access Token has expired")
+		}
+		return nil, exception("NotAuthorizedException", "Invalid Access Token")
+	}
+	sub, _ := claims["sub"].(string)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	u := e.users[sub]
+	if u == nil {
+		return nil, exception("UserNotFoundException", "User does not exist.")
+	}
+
+	attributes := make([]attribute, 0, len(u.attributes))
+	for name, value := range u.attributes {
+		attributes = append(attributes, attribute{Name: name, Value: value})
+	}
+	return map[string]any{
+		"Username":       u.sub,
+		"UserAttributes": attributes,
+	}, nil
+}
+
+// lookup finds a user by email or by sub. Callers must hold e.mu.
+func (e *emulator) lookup(username string) *user {
+	if sub, ok := e.emails[strings.ToLower(strings.TrimSpace(username))]; ok {
+		return e.users[sub]
+	}
+	return e.users[username]
+}
+
+func (e *emulator) checkClient(clientId, secretHash, username string) error {
+	if clientId != e.clientId {
+		return exception("ResourceNotFoundException", "User pool client "+clientId+" does not exist.")
+	}
+	return e.checkSecretHash(secretHash, username)
+}
+
+// checkSecretHash verifies the SECRET_HASH Cognito requires from app clients
+// with a secret. Without a configured secret any hash is accepted.
+func (e *emulator) checkSecretHash(secretHash, username string) error {
+	if e.clientSecret == "" {
+		return nil
+	}
+	h := hmac.New(sha256.New, []byte(e.clientSecret))
+	h.Write([]byte(username + e.clientId))
+	want := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(secretHash)) {
+		return exception("NotAuthorizedException", "Unable to verify secret hash for client "+e.clientId)
+	}
+	return nil
+}
+
+func (u *user) setPassword(password string) error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	hash, err := pbkdf2.Key(sha256.New, password, salt, passwordIter, 32)
+	if err != nil {
+		return err
+	}
+	u.passwordSalt, u.passwordHash = salt, hash
+	return nil
+}
+
+func (u *user) checkPassword(password string) bool {
+	hash, err := pbkdf2.Key(sha256.New, password, u.passwordSalt, passwordIter, 32)
+	return err == nil && hmac.Equal(hash, u.passwordHash)
+}
+
+func newCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// maskEmail hides most of an email the way Cognito does in code delivery
+// details, e.g. j***@e***.
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" || domain == "" {
+		return "***"
+	}
+	return local[:1] + "***@" + domain[:1] + "***"
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"app/internal/config"
+	"app/internal/db"
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// codeRecorder is a slog.Handler that keeps the last confirmation code the
+// emulator logged.
+type codeRecorder struct {
+	mu   sync.Mutex
+	code string
+}
+
+func (c *codeRecorder) Enabled(context.Context, slog.Level) bool { return true }
+func (c *codeRecorder) WithAttrs([]slog.Attr) slog.Handler       { return c }
+func (c *codeRecorder) WithGroup(string) slog.Handler            { return c }
+
+func (c *codeRecorder) Handle(_ context.Context, record slog.Record) error {
+	record.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == "code" {
+			c.mu.Lock()
+			c.code = attr.Value.String()
+			c.mu.Unlock()
+		}
+		return true
+	})
+	return nil
+}
+
+// TestCognitoStoreAgainstEmulator runs the real CognitoStore through sign
+// up, confirmation, login, token validation, user info and refresh.
+func TestCognitoStoreAgainstEmulator(t *testing.T) {
+	const (
+		poolId       = "us-east-1_local"
+		clientId     = "local-client"
+		clientSecret = "local-secret"
+		email        = "jane@example.com"
+		password     = "Passw0rd!"
+	)
+
+	codes := &codeRecorder{}
+	logger := slog.Default()
+	slog.SetDefault(slog.New(codes))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	var emu *emulator
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		emu.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	issuer := srv.URL + "/" + poolId
+	emu, err := newEmulator(issuer, poolId, clientId, clientSecret)
+	if err != nil {
+		t.Fatalf("failed to create emulator: %v", err)
+	}
+
+	store, err := db.NewCognitoStore(&config.Config{
+		AwsCognitoUserPoolId:   poolId,
+		AwsCognitoClientId:     clientId,
+		AwsCognitoClientSecret: clientSecret,
+		AwsTokenURL:            issuer + jwksPath,
+		AwsJWTIssuerURL:        issuer,
+		JwksRefreshInterval:    time.Hour,
+		JwksMinRefetchInterval: time.Minute,
+		TokenUse:               config.TokenUseAccess,
+		AwsConfig: aws.Config{
+			Region:           "us-east-1",
+			Credentials:      aws.AnonymousCredentials{},
+			BaseEndpoint:     aws.String(srv.URL),
+			RetryMaxAttempts: 1,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := store.SignUp(ctx, &models.User{Name: "Jane", Email: email, Password: password}); err != nil {
+		t.Fatalf("SignUp: %v", err)
+	}
+	if err := store.SignUp(ctx, &models.User{Name: "Jane", Email: email, Password: password}); !errors.Is(err, appError.ErrAccountExists) {
+		t.Errorf("SignUp again: err = %v, want %v", err, appError.ErrAccountExists)
+	}
+
+	_, err = store.Login(ctx, &models.UserLoginParams{Email: email, Password: password})
+	if !errors.Is(err, appError.ErrInvalidInput) {
+		t.Errorf("Login before confirming: err = %v, want %v", err, appError.ErrInvalidInput)
+	}
+
+	codes.mu.Lock()
+	code := codes.code
+	codes.mu.Unlock()
+	wrongCode := "000000"
+	if code == wrongCode {
+		wrongCode = "111111"
+	}
+	err = store.ConfirmAccount(ctx, &models.UserConfirmationParams{Email: email, Code: wrongCode})
+	if !errors.Is(err, appError.ErrInvalidCode) {
+		t.Errorf("ConfirmAccount with wrong code: err = %v, want %v", err, appError.ErrInvalidCode)
+	}
+	if err := store.ConfirmAccount(ctx, &models.UserConfirmationParams{Email: email, Code: code}); err != nil {
+		t.Fatalf("ConfirmAccount: %v", err)
+	}
+
+	_, err = store.Login(ctx, &models.UserLoginParams{Email: email, Password: "Wr0ngPass!"})
+	if !errors.Is(err, appError.ErrInvalidCredentials) {
+		t.Errorf("Login with wrong password: err = %v, want %v", err, appError.ErrInvalidCredentials)
+	}
+
+	login, err := store.Login(ctx, &models.UserLoginParams{Email: email, Password: password})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	token, err := store.ValidateToken(login.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	claims, err := store.GetClaims(token)
+	if err != nil {
+		t.Fatalf("GetClaims: %v", err)
+	}
+
+	info, err := store.GetUser(ctx, login.AccessToken)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if info.Username != claims.Username || info.Attributes["email"] != email || info.Attributes["name"] != "Jane" {
+		t.Errorf("GetUser = %+v, want username %s with email %s and name Jane", info, claims.Username, email)
+	}
+
+	refreshed, err := store.Refresh(ctx, &models.UserRefreshParams{Username: claims.Username, RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed.RefreshToken != login.RefreshToken {
+		t.Errorf("Refresh changed the refresh token")
+	}
+	if _, err := store.ValidateToken(refreshed.AccessToken); err != nil {
+		t.Errorf("ValidateToken of refreshed token: %v", err)
+	}
+
+	_, err = store.Refresh(ctx, &models.UserRefreshParams{Username: claims.Username, RefreshToken: "unknown"})
+	if !errors.Is(err, appError.ErrInvalidRefreshToken) {
+		t.Errorf("Refresh with unknown token: err = %v, want %v", err, appError.ErrInvalidRefreshToken)
+	}
+}
@@ -0,0 +1,61 @@
+// Command cognito-emulator serves enough of the Cognito Identity Provider API
+// for the service to run without AWS: SignUp, ConfirmSignUp, InitiateAuth
+// with USER_PASSWORD_AUTH and REFRESH_TOKEN_AUTH, and GetUser. Tokens are
+// signed with a key generated at startup and published at
+// <issuer>/.well-known/jwks.json. Confirmation codes are written to the log
+// instead of being emailed. Everything is kept in memory.
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("EMULATOR_ADDR", ":9229"), "address to listen on")
+	poolId := flag.String("pool-id", envOr("AWS_COGNITO_USER_POOL_ID", "us-east-1_local"), "user pool ID")
+	clientId := flag.String("client-id", envOr("AWS_COGNITO_CLIENT_ID", "local"), "app client ID")
+	clientSecret := flag.String("client-secret", os.Getenv("AWS_COGNITO_CLIENT_SECRET"), "app client secret, secret hashes are only checked when set")
+	issuer := flag.String("issuer", os.Getenv("EMULATOR_ISSUER"), "issuer of the tokens (default http://localhost<addr>/<pool-id>)")
+	flag.Parse()
+
+	if *issuer == "" {
+		host := *addr
+		if strings.HasPrefix(host, ":") {
+			host = "localhost" + host
+		}
+		*issuer = "http://" + host + "/" + *poolId
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	emu, err := newEmulator(*issuer, *poolId, *clientId, *clientSecret)
+	if err != nil {
+		slog.Error("failed to start emulator", "err", err)
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:         *addr,
+		Handler:      emu,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	slog.Info("cognito emulator running", "addr", *addr, "issuer", *issuer, "jwks", *issuer+jwksPath, "client_id", *clientId)
+	if err := server.ListenAndServe(); err != nil {
+		slog.Error("emulator stopped", "err", err)
+		os.Exit(1)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
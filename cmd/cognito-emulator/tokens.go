@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+const (
+	jwksPath = "/.well-known/jwks.json"
+
+	accessTokenTTL  = time.Hour
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// signer issues RS256 tokens shaped like Cognito's and publishes the key
+// that verifies them.
+type signer struct {
+	issuer   string
+	clientId string
+	keyId    string
+	key      *rsa.PrivateKey
+	jwks     []byte
+}
+
+func newSigner(issuer, clientId string) (*signer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	keyId, err := randomHex(8)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key ID: %w", err)
+	}
+
+	publicKey, err := jwk.FromRaw(&key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build JWK: %w", err)
+	}
+	publicKey.Set(jwk.KeyIDKey, keyId)
+	publicKey.Set(jwk.AlgorithmKey, jwa.RS256)
+	publicKey.Set(jwk.KeyUsageKey, jwk.ForSignature)
+	set := jwk.NewSet()
+	set.AddKey(publicKey)
+	jwks, err := json.Marshal(set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JWK set: %w", err)
+	}
+
+	return &signer{
+		issuer:   issuer,
+		clientId: clientId,
+		keyId:    keyId,
+		key:      key,
+		jwks:     jwks,
+	}, nil
+}
+
+func (s *signer) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.jwks)
+}
+
+// accessToken signs an access token for u. originJti ties together every
+// access token minted from the same refresh token, as Cognito does.
+func (s *signer) accessToken(u *user, originJti string, now time.Time) (string, error) {
+	jti, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+	return s.sign(jwt.MapClaims{
+		"sub":        u.sub,
+		"iss":        s.issuer,
+		"client_id":  s.clientId,
+		"origin_jti": originJti,
+		"token_use":  "access",
+		"scope":      "aws.cognito.signin.user.admin",
+		"auth_time":  now.Unix(),
+		"iat":        now.Unix(),
+		"exp":        now.Add(accessTokenTTL).Unix(),
+		"jti":        jti,
+		"username":   u.sub,
+	})
+}
+
+func (s *signer) idToken(u *user, originJti string, now time.Time) (string, error) {
+	jti, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{
+		"sub":              u.sub,
+		"iss":              s.issuer,
+		"aud":              s.clientId,
+		"origin_jti":       originJti,
+		"token_use":        "id",
+		"auth_time":        now.Unix(),
+		"iat":              now.Unix(),
+		"exp":              now.Add(accessTokenTTL).Unix(),
+		"jti":              jti,
+		"cognito:username": u.sub,
+		"email_verified":   u.confirmed,
+	}
+	for name, value := range u.attributes {
+		if _, taken := claims[name]; !taken {
+			claims[name] = value
+		}
+	}
+	return s.sign(claims)
+}
+
+func (s *signer) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.keyId
+	return token.SignedString(s.key)
+}
+
+// parseAccessToken checks an access token this emulator issued and returns
+// its claims.
+func (s *signer) parseAccessToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(*jwt.Token) (any, error) {
+		return &s.key.PublicKey, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if claims["token_use"] != "access" {
+		return nil, fmt.Errorf("not an access token")
+	}
+	return claims, nil
+}
+
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	appError "app/internal/errors"
+	"app/internal/models"
+	"net/http"
+	"strings"
+)
+
+// RequireGroups lets a request through only when the token belongs to every
+// one of groups. It reads the claims set by jwtAuthMiddleware, which must run
+// first.
+func RequireGroups(groups ...string) func(http.Handler) http.Handler {
+	return requireClaims(func(claims *models.Claims) bool {
+		for _, group := range groups {
+			if !claims.HasGroup(group) {
+				return false
+			}
+		}
+		return true
+	}, "requires groups "+strings.Join(groups, ", "))
+}
+
+// RequireAnyGroup lets a request through when the token belongs to at least
+// one of groups.
+func RequireAnyGroup(groups ...string) func(http.Handler) http.Handler {
+	return requireClaims(func(claims *models.Claims) bool {
+		for _, group := range groups {
+			if claims.HasGroup(group) {
+				return true
+			}
+		}
+		return false
+	}, "requires one of groups "+strings.Join(groups, ", "))
+}
+
+// RequireScopes lets a request through only when the token was granted every
+// one of scopes.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return requireClaims(func(claims *models.Claims) bool {
+		for _, scope := range scopes {
+			if !claims.HasScope(scope) {
+				return false
+			}
+		}
+		return true
+	}, "requires scopes "+strings.Join(scopes, ", "))
+}
+
+func requireClaims(allowed func(*models.Claims) bool, detail string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCtx, ok := models.FromContext(r.Context())
+			if !ok {
+				models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+				return
+			}
+
+			if !allowed(reqCtx.Claims) {
+				authErr := appError.NewForbiddenError(detail)
+				models.ResponseWithError(w, r, models.NewAuthErrorResponse(authErr))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
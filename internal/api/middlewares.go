@@ -2,8 +2,10 @@ package api
 
 import (
 	"app/internal/db"
+	appError "app/internal/errors"
 	"app/internal/models"
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -38,36 +40,62 @@ func requestLogger(next http.Handler) http.Handler {
 	})
 }
 
-func jwtAuthMiddleware(authStore db.AuthStore) func(http.Handler) http.Handler {
+// errorDetails exposes the upstream error behind error responses in their
+// debug field. Only used when ENV=local.
+func errorDetails(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(models.WithErrorDetails(r.Context())))
+	})
+}
+
+// clientIPContext makes the client's address available to services through
+// models.ClientIP. It must run after middleware.RealIP.
+func clientIPContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(models.WithClientIP(r.Context(), clientIP(r))))
+	})
+}
+
+func jwtAuthMiddleware(authStore db.AuthStore, revocations db.RevocationList) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			header := r.Header.Get("Authorization")
 			if header == "" {
-				models.ResponseWithJSON(w, http.StatusUnauthorized, models.NewErrorResponse(http.StatusUnauthorized, "Authorization header required"))
+				models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization header required"))
 				return
 			}
 
 			parts := strings.Split(header, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				models.ResponseWithJSON(w, http.StatusUnauthorized, models.NewErrorResponse(http.StatusUnauthorized, "Invalid authorization header format"))
+				models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Invalid authorization header format"))
 				return
 			}
 
 			token, err := authStore.ValidateToken(parts[1])
 			if err != nil {
-				models.ResponseWithJSON(w, http.StatusUnauthorized, models.NewErrorResponse(http.StatusUnauthorized, "Invalid authorization token "+err.Error()))
+				var authErr *appError.AuthError
+				if errors.As(err, &authErr) {
+					models.ResponseWithError(w, r, models.NewAuthErrorResponse(authErr))
+					return
+				}
+				models.ResponseWithError(w, r, models.NewAuthErrorResponse(appError.NewInvalidTokenError("").WithCause(err)))
 				return
 			}
 
-			userInfo, err := authStore.GetClaims(token)
+			claims, err := authStore.GetClaims(token)
 			if err != nil {
-				models.ResponseWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse(http.StatusInternalServerError, "Failed to extract user info"))
+				models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusInternalServerError, "Failed to extract user info").WithCause(err))
+				return
+			}
+
+			if revocations.IsRevoked(claims) {
+				models.ResponseWithError(w, r, models.NewAuthErrorResponse(appError.NewRevokedTokenError()))
 				return
 			}
 
 			ctx := context.WithValue(r.Context(), models.RequestContextKey, &models.RequestContext{
-				UserInfo: userInfo,
-				Token:    parts[1],
+				Claims: claims,
+				Token:  parts[1],
 			})
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"app/internal/config"
+	"app/internal/db"
+	appError "app/internal/errors"
+	"app/internal/models"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateLimit limits requests to route by client IP and by the email in the
+// JSON body, using the limits configured for route. It must run after
+// middleware.RealIP so RemoteAddr is the client's address. Requests over
+// either limit get a 429 with Retry-After; every limited request gets the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers of the
+// tighter of the two. A request already over its IP limit isn't counted
+// against the email, so one address can't lock another out of its account.
+// If the store fails the request is let through.
+func rateLimit(store db.RateLimitStore, route string, limits config.RouteRateLimits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var decisions []db.RateLimitDecision
+			take := func(key string, limit config.RateLimit) bool {
+				if limit.Requests <= 0 {
+					return true
+				}
+				decision, err := store.Take(r.Context(), key, limit)
+				if err != nil {
+					slog.Warn("rate limit store failed", "route", route, "err", err)
+					return true
+				}
+				decisions = append(decisions, decision)
+				return decision.Allowed
+			}
+
+			if take(route+":ip:"+clientIP(r), limits.IP) && limits.Email.Requests > 0 {
+				if email := requestEmail(r); email != "" {
+					take(route+":email:"+email, limits.Email)
+				}
+			}
+			if len(decisions) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tightest := decisions[0]
+			var retryAfter time.Duration
+			for _, decision := range decisions {
+				if decision.Remaining < tightest.Remaining {
+					tightest = decision
+				}
+				if !decision.Allowed && decision.RetryAfter > retryAfter {
+					retryAfter = decision.RetryAfter
+				}
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(tightest.Reset)))
+
+			if retryAfter > 0 {
+				seconds := ceilSeconds(retryAfter)
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				authErr := appError.NewTooManyRequestsError("Please try again in " + strconv.Itoa(seconds) + " seconds")
+				models.ResponseWithError(w, r, models.NewAuthErrorResponse(authErr))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP is the address RealIP left in RemoteAddr, without the port.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// requestEmail reads the email field of a JSON body, normalised so that case
+// and whitespace don't give a caller fresh buckets. The body is put back for
+// the handler, including any read error such as the size limit being hit.
+func requestEmail(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	var rest io.Reader = bytes.NewReader(body)
+	if err != nil {
+		rest = io.MultiReader(rest, &errReader{err: err})
+	}
+	r.Body = io.NopCloser(rest)
+	if err != nil {
+		return ""
+	}
+
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(payload.Email))
+}
+
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
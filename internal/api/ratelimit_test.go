@@ -0,0 +1,63 @@
+package api_test
+
+import (
+	"app/internal/config"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	const email = "jane@example.com"
+
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.RateLimits = map[string]config.RouteRateLimits{
+			config.RateLimitLogin: {
+				IP:    config.RateLimit{Requests: 4, Period: time.Minute},
+				Email: config.RateLimit{Requests: 2, Period: time.Minute},
+			},
+		}
+	})
+	login := func(ip, email string) *response {
+		return s.do(http.MethodPost, "/auth/login", "", map[string]any{"email": email, "password": "Wr0ngPass!"}, http.Header{"X-Real-Ip": {ip}})
+	}
+
+	steps := []struct {
+		name          string
+		ip            string
+		email         string
+		status        int
+		code          string
+		wantLimit     string
+		wantRemaining string
+		wantRetry     string
+	}{
+		// The body still reaches the handler after the email was read from
+		// it, so the login itself fails.
+		{name: "first", ip: "192.0.2.1", email: email, status: http.StatusUnauthorized, code: "INVALID_CREDENTIALS", wantLimit: "2", wantRemaining: "1"},
+		{name: "email case is ignored", ip: "192.0.2.1", email: "Jane@Example.com", status: http.StatusUnauthorized, code: "INVALID_CREDENTIALS", wantLimit: "2", wantRemaining: "0"},
+		{name: "over the email limit", ip: "192.0.2.1", email: email, status: http.StatusTooManyRequests, code: "TOO_MANY_REQUESTS", wantLimit: "2", wantRemaining: "0", wantRetry: "30"},
+		{name: "last request from the IP", ip: "192.0.2.1", email: "john@example.com", status: http.StatusUnauthorized, code: "INVALID_CREDENTIALS", wantLimit: "4", wantRemaining: "0"},
+		{name: "over the IP limit", ip: "192.0.2.1", email: "john@example.com", status: http.StatusTooManyRequests, code: "TOO_MANY_REQUESTS", wantLimit: "4", wantRemaining: "0", wantRetry: "15"},
+		// john@example.com was not counted while the IP was over its limit,
+		// so it has one request left.
+		{name: "another IP", ip: "192.0.2.2", email: "john@example.com", status: http.StatusUnauthorized, code: "INVALID_CREDENTIALS", wantLimit: "2", wantRemaining: "0"},
+	}
+
+	for _, step := range steps {
+		res := login(step.ip, step.email)
+		expect(t, step.name, res, step.status, step.code)
+		if got := res.header.Get("RateLimit-Limit"); got != step.wantLimit {
+			t.Errorf("%s: RateLimit-Limit = %q, want %q", step.name, got, step.wantLimit)
+		}
+		if got := res.header.Get("RateLimit-Remaining"); got != step.wantRemaining {
+			t.Errorf("%s: RateLimit-Remaining = %q, want %q", step.name, got, step.wantRemaining)
+		}
+		if got := res.header.Get("RateLimit-Reset"); got == "" {
+			t.Errorf("%s: RateLimit-Reset missing", step.name)
+		}
+		if got := res.header.Get("Retry-After"); got != step.wantRetry {
+			t.Errorf("%s: Retry-After = %q, want %q", step.name, got, step.wantRetry)
+		}
+	}
+}
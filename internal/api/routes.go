@@ -6,7 +6,7 @@ import (
 	"app/internal/handlers"
 	"app/internal/models"
 	"app/internal/services"
-	"log/slog"
+	"app/internal/validation"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -14,47 +14,113 @@ import (
 	"github.com/go-chi/cors"
 )
 
-func newRoutes(cfg *config.Config) http.Handler {
+// NewRouter builds the API's routes on top of authStore, so callers such as
+// tests can pick the store instead of the one cfg.AuthBackend selects.
+func NewRouter(cfg *config.Config, authStore db.AuthStore) http.Handler {
 	router := chi.NewRouter()
 
 	router.Use(middleware.Heartbeat("/ping"))
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.RealIP)
+	router.Use(clientIPContext)
 	router.Use(middleware.RequestID)
 	router.Use(requestLogger)
+	if cfg.Env == "local" {
+		router.Use(errorDetails)
+	}
+	router.Use(middleware.RequestSize(cfg.MaxRequestBodyBytes))
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	authStore, err := db.NewCognitoStore(cfg)
-	if err != nil {
-		slog.Error("failed to initialize auth store", "err", err)
-		panic(err)
+	revocations := db.NewMemoryRevocationList()
+	rateLimits := db.NewMemoryRateLimitStore()
+	limited := func(route string) func(http.Handler) http.Handler {
+		return rateLimit(rateLimits, route, cfg.RateLimits[route])
 	}
+	validator := validation.New(cfg.PasswordPolicy)
 	authHandlers := handlers.NewAuthHandlers(
 		services.NewAuthService(
 			authStore,
+			revocations,
+			cfg.LoginLockout,
 		),
+		validator,
 	)
 	authRouter := chi.NewRouter()
 
-	authRouter.Post("/signup", authHandlers.SignUp)
-	authRouter.Post("/login", authHandlers.Login)
-	authRouter.Post("/confirm", authHandlers.ConfirmAccount)
+	authRouter.With(limited(config.RateLimitSignUp)).Post("/signup", authHandlers.SignUp)
+	authRouter.With(limited(config.RateLimitLogin)).Post("/login", authHandlers.Login)
+	authRouter.With(limited(config.RateLimitChallenge)).Post("/challenge", authHandlers.RespondToChallenge)
+	authRouter.With(limited(config.RateLimitConfirm)).Post("/confirm", authHandlers.ConfirmAccount)
+	authRouter.With(limited(config.RateLimitResendCode)).Post("/confirm/resend", authHandlers.ResendConfirmationCode)
+	authRouter.Post("/refresh", authHandlers.Refresh)
+	authRouter.With(limited(config.RateLimitForgotPassword)).Post("/password/forgot", authHandlers.ForgotPassword)
+	authRouter.With(limited(config.RateLimitResetPassword)).Post("/password/reset", authHandlers.ResetPassword)
 
 	authRouter.Group(func(r chi.Router) {
-		r.Use(jwtAuthMiddleware(authStore))
+		r.Use(jwtAuthMiddleware(authStore, revocations))
 		r.Get("/protected", func(w http.ResponseWriter, r *http.Request) {
 			models.ResponseWithJSON(w, http.StatusOK, models.NewDataResponse(http.StatusOK, "Protected route"))
 		})
 		r.Get("/user/info", authHandlers.GetUser)
+		r.Patch("/user/info", authHandlers.UpdateUser)
+		r.Post("/user/verify-attribute", authHandlers.SendAttributeVerification)
+		r.Post("/user/verify-attribute/confirm", authHandlers.ConfirmAttribute)
+		r.Post("/user/password", authHandlers.ChangePassword)
+		r.Delete("/user", authHandlers.DeleteUser)
+		r.Post("/logout", authHandlers.Logout)
+		r.Post("/logout/all", authHandlers.LogoutAll)
+
+		r.Route("/mfa", func(r chi.Router) {
+			r.Post("/totp", authHandlers.StartMFAEnrollment)
+			r.Post("/totp/verify", authHandlers.VerifyMFAEnrollment)
+			r.Put("/preference", authHandlers.SetMFAPreference)
+			r.Delete("/", authHandlers.DisableMFA)
+		})
 	})
 
 	router.Mount("/auth", authRouter)
+
+	adminHandlers := handlers.NewAdminHandlers(
+		services.NewAdminService(
+			authStore,
+			revocations,
+		),
+		validator,
+	)
+	adminRouter := chi.NewRouter()
+	adminRouter.Use(jwtAuthMiddleware(authStore, revocations))
+	adminRouter.Use(RequireGroups(cfg.AdminGroup))
+
+	adminRouter.Get("/protected", func(w http.ResponseWriter, r *http.Request) {
+		models.ResponseWithJSON(w, http.StatusOK, models.NewDataResponse(http.StatusOK, "Admin route"))
+	})
+	adminRouter.Route("/users", func(r chi.Router) {
+		r.Get("/", adminHandlers.ListUsers)
+		r.Get("/{username}", adminHandlers.GetUser)
+		r.Delete("/{username}", adminHandlers.DeleteUser)
+		r.Post("/{username}/disable", adminHandlers.DisableUser)
+		r.Post("/{username}/enable", adminHandlers.EnableUser)
+		r.Post("/{username}/confirm", adminHandlers.ConfirmUser)
+		r.Post("/{username}/reset-password", adminHandlers.ResetUserPassword)
+		r.Post("/{username}/sign-out", adminHandlers.SignOutUser)
+		r.Get("/{username}/groups", adminHandlers.ListUserGroups)
+		r.Post("/{username}/groups/{group}", adminHandlers.AddUserToGroup)
+		r.Delete("/{username}/groups/{group}", adminHandlers.RemoveUserFromGroup)
+	})
+
+	adminRouter.Route("/groups", func(r chi.Router) {
+		r.Get("/", adminHandlers.ListGroups)
+		r.Post("/", adminHandlers.CreateGroup)
+		r.Delete("/{group}", adminHandlers.DeleteGroup)
+	})
+
+	router.Mount("/admin", adminRouter)
 	return router
 }
@@ -0,0 +1,364 @@
+package api_test
+
+import (
+	"app/internal/api"
+	"app/internal/config"
+	"app/internal/db"
+	"app/internal/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// codeRecorder is a slog.Handler that keeps the codes MemoryStore logs
+// instead of emailing, keyed by email.
+type codeRecorder struct {
+	mu    sync.Mutex
+	codes map[string]string
+}
+
+func (c *codeRecorder) Enabled(context.Context, slog.Level) bool { return true }
+func (c *codeRecorder) WithAttrs([]slog.Attr) slog.Handler       { return c }
+func (c *codeRecorder) WithGroup(string) slog.Handler            { return c }
+
+func (c *codeRecorder) Handle(_ context.Context, record slog.Record) error {
+	var email, code string
+	record.Attrs(func(attr slog.Attr) bool {
+		switch attr.Key {
+		case "email":
+			email = attr.Value.String()
+		case "code":
+			code = attr.Value.String()
+		}
+		return true
+	})
+	if email != "" && code != "" {
+		c.mu.Lock()
+		c.codes[email] = code
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+func (c *codeRecorder) code(email string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.codes[email]
+}
+
+type testServer struct {
+	t     *testing.T
+	url   string
+	store *db.MemoryStore
+	codes *codeRecorder
+}
+
+// newTestServer starts the router on a MemoryStore. configure, if given,
+// adjusts the config first.
+func newTestServer(t *testing.T, configure ...func(*config.Config)) *testServer {
+	t.Helper()
+
+	codes := &codeRecorder{codes: make(map[string]string)}
+	logger := slog.Default()
+	slog.SetDefault(slog.New(codes))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	cfg := &config.Config{
+		Env:                 "test",
+		AuthBackend:         "memory",
+		TokenUse:            config.TokenUseAccess,
+		AdminGroup:          "admin",
+		MaxRequestBodyBytes: 64 << 10,
+		PasswordPolicy: config.PasswordPolicy{
+			MinLength:        8,
+			RequireUppercase: true,
+			RequireLowercase: true,
+			RequireNumbers:   true,
+			RequireSymbols:   true,
+		},
+	}
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	store, err := db.NewMemoryStore(cfg)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	srv := httptest.NewServer(api.NewRouter(cfg, store))
+	t.Cleanup(srv.Close)
+
+	return &testServer{t: t, url: srv.URL, store: store, codes: codes}
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   map[string]any
+}
+
+// data is the data member of a success envelope.
+func (r *response) data() map[string]any {
+	data, _ := r.body["data"].(map[string]any)
+	return data
+}
+
+func (s *testServer) do(method, path, token string, body any, header http.Header) *response {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("failed to encode body: %v", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, s.url+path, reader)
+	if err != nil {
+		s.t.Fatalf("failed to build request: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+
+	out := &response{status: res.StatusCode, header: res.Header}
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		s.t.Fatalf("%s %s: failed to read body: %v", method, path, err)
+	}
+	if len(raw) > 0 && json.Unmarshal(raw, &out.body) != nil {
+		s.t.Fatalf("%s %s: body is not a JSON object: %s", method, path, raw)
+	}
+	return out
+}
+
+// expect fails the test unless res has status and, for errors, code in a
+// well formed envelope.
+func expect(t *testing.T, step string, res *response, status int, code string) {
+	t.Helper()
+
+	if res.status != status {
+		t.Fatalf("%s: status = %d, want %d (body %v)", step, res.status, status, res.body)
+	}
+	if got, _ := res.body["status"].(float64); int(got) != status {
+		t.Errorf("%s: envelope status = %v, want %d", step, res.body["status"], status)
+	}
+	if status >= 400 {
+		if res.body["code"] != code {
+			t.Errorf("%s: code = %v, want %s", step, res.body["code"], code)
+		}
+		if msg, _ := res.body["error"].(string); msg == "" {
+			t.Errorf("%s: error message missing from %v", step, res.body)
+		}
+		if id, _ := res.body["request_id"].(string); id == "" {
+			t.Errorf("%s: request_id missing from %v", step, res.body)
+		}
+		return
+	}
+	if _, ok := res.body["data"]; !ok {
+		t.Errorf("%s: data missing from %v", step, res.body)
+	}
+}
+
+func TestAuthFlow(t *testing.T) {
+	s := newTestServer(t)
+
+	const (
+		email    = "jane@example.com"
+		password = "Passw0rd!"
+	)
+
+	res := s.do(http.MethodPost, "/auth/signup", "", map[string]any{"name": "Jane", "email": email, "password": password}, nil)
+	expect(t, "signup", res, http.StatusCreated, "")
+
+	res = s.do(http.MethodPost, "/auth/signup", "", map[string]any{"name": "Jane", "email": email, "password": password}, nil)
+	expect(t, "signup again", res, http.StatusConflict, "ACCOUNT_EXISTS")
+
+	res = s.do(http.MethodPost, "/auth/login", "", map[string]any{"email": email, "password": password}, nil)
+	expect(t, "login before confirming", res, http.StatusBadRequest, "INVALID_INPUT")
+
+	res = s.do(http.MethodPost, "/auth/confirm", "", map[string]any{"email": email, "code": "000000"}, nil)
+	expect(t, "confirm with wrong code", res, http.StatusBadRequest, "CODE_INVALID")
+
+	code := s.codes.code(email)
+	if code == "" {
+		t.Fatal("no confirmation code was issued")
+	}
+	res = s.do(http.MethodPost, "/auth/confirm", "", map[string]any{"email": email, "code": code}, nil)
+	expect(t, "confirm", res, http.StatusOK, "")
+
+	res = s.do(http.MethodPost, "/auth/login", "", map[string]any{"email": email, "password": "Wr0ngPass!"}, nil)
+	expect(t, "login with wrong password", res, http.StatusUnauthorized, "INVALID_CREDENTIALS")
+
+	res = s.do(http.MethodPost, "/auth/login", "", map[string]any{"email": email, "password": password}, nil)
+	expect(t, "login", res, http.StatusOK, "")
+	token, _ := res.data()["access_token"].(string)
+	if token == "" || res.data()["refresh_token"] == "" {
+		t.Fatalf("login: tokens missing from %v", res.body)
+	}
+
+	res = s.do(http.MethodGet, "/auth/user/info", token, nil, nil)
+	expect(t, "user info", res, http.StatusOK, "")
+	attributes, _ := res.data()["attributes"].(map[string]any)
+	if attributes["email"] != email || attributes["name"] != "Jane" {
+		t.Errorf("user info: attributes = %v, want email %s and name Jane", attributes, email)
+	}
+	username, _ := res.data()["username"].(string)
+
+	res = s.do(http.MethodGet, "/auth/protected", token, nil, nil)
+	expect(t, "protected", res, http.StatusOK, "")
+
+	res = s.do(http.MethodGet, "/admin/protected", token, nil, nil)
+	expect(t, "admin route without the admin group", res, http.StatusForbidden, "FORBIDDEN")
+
+	if _, err := s.store.CreateGroup(context.Background(), &models.CreateGroupParams{Name: "admin"}); err != nil {
+		t.Fatalf("failed to create admin group: %v", err)
+	}
+	if err := s.store.AdminAddUserToGroup(context.Background(), username, "admin"); err != nil {
+		t.Fatalf("failed to add user to admin group: %v", err)
+	}
+	res = s.do(http.MethodPost, "/auth/login", "", map[string]any{"email": email, "password": password}, nil)
+	expect(t, "login as admin", res, http.StatusOK, "")
+	adminToken, _ := res.data()["access_token"].(string)
+	refreshToken, _ := res.data()["refresh_token"].(string)
+
+	res = s.do(http.MethodGet, "/admin/protected", adminToken, nil, nil)
+	expect(t, "admin route", res, http.StatusOK, "")
+
+	res = s.do(http.MethodPost, "/auth/logout", adminToken, map[string]any{"refresh_token": refreshToken}, nil)
+	expect(t, "logout", res, http.StatusOK, "")
+
+	res = s.do(http.MethodGet, "/auth/protected", adminToken, nil, nil)
+	expect(t, "protected after logout", res, http.StatusUnauthorized, "TOKEN_REVOKED")
+}
+
+func TestProtectedRoutesRejectMissingTokens(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header http.Header
+		want   int
+		code   string
+	}{
+		{"no header", http.MethodGet, "/auth/user/info", nil, http.StatusUnauthorized, "UNAUTHORIZED"},
+		{"not a bearer token", http.MethodGet, "/auth/protected", http.Header{"Authorization": {"Basic abc"}}, http.StatusUnauthorized, "UNAUTHORIZED"},
+		{"malformed token", http.MethodGet, "/auth/protected", http.Header{"Authorization": {"Bearer abc"}}, http.StatusUnauthorized, "TOKEN_INVALID"},
+		{"admin route", http.MethodGet, "/admin/users", nil, http.StatusUnauthorized, "UNAUTHORIZED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := s.do(tt.method, tt.path, "", nil, tt.header)
+			expect(t, tt.name, res, tt.want, tt.code)
+		})
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	s := newTestServer(t)
+
+	t.Run("request ID from the client", func(t *testing.T) {
+		res := s.do(http.MethodGet, "/auth/protected", "", nil, http.Header{"X-Request-Id": {"e2e-request-1"}})
+		expect(t, "protected", res, http.StatusUnauthorized, "UNAUTHORIZED")
+		if res.body["request_id"] != "e2e-request-1" {
+			t.Errorf("request_id = %v, want e2e-request-1", res.body["request_id"])
+		}
+	})
+
+	t.Run("generated request IDs differ", func(t *testing.T) {
+		first := s.do(http.MethodGet, "/auth/protected", "", nil, nil)
+		second := s.do(http.MethodGet, "/auth/protected", "", nil, nil)
+		if first.body["request_id"] == second.body["request_id"] {
+			t.Errorf("request_id %v was reused", first.body["request_id"])
+		}
+	})
+
+	t.Run("validation details", func(t *testing.T) {
+		res := s.do(http.MethodPost, "/auth/signup", "", map[string]any{"name": "Jane", "email": "not-an-email", "password": "short"}, nil)
+		expect(t, "signup", res, http.StatusUnprocessableEntity, "VALIDATION_FAILED")
+		details, _ := res.body["details"].([]any)
+		if len(details) != 2 {
+			t.Errorf("details = %v, want email and password", details)
+		}
+	})
+
+	t.Run("unknown fields", func(t *testing.T) {
+		res := s.do(http.MethodPost, "/auth/login", "", map[string]any{"email": "jane@example.com", "password": "x", "admin": true}, nil)
+		expect(t, "login", res, http.StatusBadRequest, "BAD_REQUEST")
+	})
+
+	t.Run("problem JSON", func(t *testing.T) {
+		res := s.do(http.MethodGet, "/auth/protected", "", nil, http.Header{"Accept": {"application/problem+json"}})
+		if res.status != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", res.status)
+		}
+		if got := res.header.Get("Content-Type"); got != "application/problem+json" {
+			t.Errorf("Content-Type = %q, want application/problem+json", got)
+		}
+		if res.body["title"] != "Unauthorized" || res.body["code"] != "UNAUTHORIZED" || res.body["request_id"] == "" {
+			t.Errorf("body = %v, want an RFC 7807 problem with code and request_id", res.body)
+		}
+	})
+}
+
+func TestCORS(t *testing.T) {
+	s := newTestServer(t)
+	const origin = "https://app.example.com"
+
+	t.Run("preflight", func(t *testing.T) {
+		res := s.do(http.MethodOptions, "/auth/login", "", nil, http.Header{
+			"Origin":                         {origin},
+			"Access-Control-Request-Method":  {http.MethodPost},
+			"Access-Control-Request-Headers": {"Content-Type, Authorization"},
+		})
+		if res.status != http.StatusOK {
+			t.Fatalf("status = %d, want 200", res.status)
+		}
+		if got := res.header.Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+		}
+		if got := res.header.Get("Access-Control-Allow-Methods"); got != http.MethodPost {
+			t.Errorf("Access-Control-Allow-Methods = %q, want POST", got)
+		}
+		if got := strings.ToLower(res.header.Get("Access-Control-Allow-Headers")); !strings.Contains(got, "authorization") || !strings.Contains(got, "content-type") {
+			t.Errorf("Access-Control-Allow-Headers = %q, want Content-Type and Authorization", got)
+		}
+		if got := res.header.Get("Access-Control-Allow-Credentials"); got != "true" {
+			t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+		}
+	})
+
+	t.Run("actual request", func(t *testing.T) {
+		res := s.do(http.MethodGet, "/auth/protected", "", nil, http.Header{"Origin": {origin}})
+		if got := res.header.Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+		}
+		if got := res.header.Get("Access-Control-Expose-Headers"); !strings.Contains(got, "Retry-After") {
+			t.Errorf("Access-Control-Expose-Headers = %q, want Retry-After exposed", got)
+		}
+	})
+}
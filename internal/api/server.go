@@ -2,6 +2,7 @@ package api
 
 import (
 	"app/internal/config"
+	"app/internal/db"
 	"context"
 	"log/slog"
 	"net/http"
@@ -12,9 +13,15 @@ import (
 )
 
 func Run(cfg *config.Config) {
+	authStore, err := db.NewAuthStore(cfg)
+	if err != nil {
+		slog.Error("failed to initialize auth store", "err", err)
+		panic(err)
+	}
+
 	server := &http.Server{
 		Addr:         cfg.Port,
-		Handler:      newRoutes(cfg),
+		Handler:      NewRouter(cfg, authStore),
 		WriteTimeout: time.Second * 30,
 		ReadTimeout:  time.Second * 10,
 		IdleTimeout:  time.Minute,
@@ -37,7 +44,7 @@ func Run(cfg *config.Config) {
 
 	slog.Info("server started running", "port", cfg.Port)
 
-	err := server.ListenAndServe()
+	err = server.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
 		slog.Error("error on starting server", "err", err)
 	}
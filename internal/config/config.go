@@ -4,21 +4,131 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/spf13/viper"
 )
 
+// Accepted values of TOKEN_USE, the kind of Cognito token the API takes as a
+// bearer token.
+const (
+	TokenUseAccess = "access"
+	TokenUseID     = "id"
+	TokenUseBoth   = "both"
+)
+
+// PasswordPolicy mirrors the password policy of the user pool, so passwords
+// Cognito would refuse are rejected with the other validation errors.
+type PasswordPolicy struct {
+	MinLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireNumbers   bool
+	RequireSymbols   bool
+}
+
+// CognitoClient tunes how the Cognito client reaches the user pool. Zero
+// values leave the AWS SDK defaults, which come from the environment and the
+// shared config files, in place.
+type CognitoClient struct {
+	// EndpointURL replaces the regional Cognito endpoint, e.g. with a VPC
+	// endpoint or a local emulator.
+	EndpointURL string
+	Region      string
+	// Profile picks the shared config profile credentials are loaded from.
+	Profile          string
+	RetryMode        aws.RetryMode
+	RetryMaxAttempts int
+	// HTTPTimeout bounds a whole request, ConnectTimeout opening the
+	// connection and ResponseHeaderTimeout waiting for the response headers.
+	HTTPTimeout           time.Duration
+	ConnectTimeout        time.Duration
+	ResponseHeaderTimeout time.Duration
+}
+
+// LoginLockout protects Login against brute force. Every failed login waits
+// BackoffBase, doubling with each further failure up to BackoffMax, before the
+// next attempt for the same account or IP. MaxFailures failures of an account
+// within Window lock it for LockoutDuration, and IPMaxFailures failures from
+// one IP within Window block that IP for as long. A zero MaxFailures or
+// IPMaxFailures turns that lock off.
+type LoginLockout struct {
+	MaxFailures     int
+	IPMaxFailures   int
+	Window          time.Duration
+	LockoutDuration time.Duration
+	BackoffBase     time.Duration
+	BackoffMax      time.Duration
+}
+
+// RateLimit allows Requests per Period, in bursts of up to Requests. A zero
+// RateLimit doesn't limit anything.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// RouteRateLimits limits one route by client IP and by the email in the
+// request body.
+type RouteRateLimits struct {
+	IP    RateLimit
+	Email RateLimit
+}
+
+// Routes that can be rate limited, the keys of Config.RateLimits.
+const (
+	RateLimitLogin          = "login"
+	RateLimitSignUp         = "signup"
+	RateLimitConfirm        = "confirm"
+	RateLimitResendCode     = "resend_code"
+	RateLimitForgotPassword = "forgot_password"
+	RateLimitResetPassword  = "reset_password"
+	RateLimitChallenge      = "challenge"
+)
+
+// rateLimitDefaults are the limits used unless RATE_LIMIT_<ROUTE>_IP or
+// RATE_LIMIT_<ROUTE>_EMAIL say otherwise, written as requests/period.
+// Challenge responses carry a session rather than an email, so they are
+// limited by IP alone.
+var rateLimitDefaults = map[string][2]string{
+	RateLimitLogin:          {"20/1m", "5/1m"},
+	RateLimitSignUp:         {"10/1m", "3/1m"},
+	RateLimitConfirm:        {"20/1m", "5/1m"},
+	RateLimitResendCode:     {"10/1m", "3/1m"},
+	RateLimitForgotPassword: {"10/1m", "3/1m"},
+	RateLimitResetPassword:  {"20/1m", "5/1m"},
+	RateLimitChallenge:      {"20/1m", "off"},
+}
+
 type Config struct {
 	Env                    string
 	Port                   string
+	AuthBackend            string
 	AwsCognitoUserPoolId   string
 	AwsCognitoClientId     string
 	AwsCognitoClientSecret string
-	AwsConfig              aws.Config
-	AwsTokenURL            string
-	AwsJWTIssuerURL        string
+	// AwsCognitoAllowedClientIds lists the app clients whose tokens are
+	// accepted. Empty means only AwsCognitoClientId.
+	AwsCognitoAllowedClientIds []string
+	AwsConfig                  aws.Config
+	CognitoClient              CognitoClient
+	AwsTokenURL                string
+	AwsJWTIssuerURL            string
+	MfaTotpIssuer              string
+	JwksRefreshInterval        time.Duration
+	JwksMinRefetchInterval     time.Duration
+	TokenUse                   string
+	TokenLeeway                time.Duration
+	AdminGroup                 string
+	PasswordPolicy             PasswordPolicy
+	MaxRequestBodyBytes        int64
+	RateLimits                 map[string]RouteRateLimits
+	LoginLockout               LoginLockout
 }
 
 func Load() (*Config, error) {
@@ -26,6 +136,29 @@ func Load() (*Config, error) {
 
 	v.SetDefault("ENV", "local")
 	v.SetDefault("PORT", "8080")
+	v.SetDefault("AUTH_BACKEND", "cognito")
+	v.SetDefault("MFA_TOTP_ISSUER", "Cognito Auth")
+	v.SetDefault("JWKS_REFRESH_INTERVAL", "1h")
+	v.SetDefault("JWKS_MIN_REFETCH_INTERVAL", "1m")
+	v.SetDefault("TOKEN_USE", TokenUseAccess)
+	v.SetDefault("TOKEN_LEEWAY", "0s")
+	v.SetDefault("ADMIN_GROUP", "admin")
+	v.SetDefault("PASSWORD_MIN_LENGTH", 8)
+	v.SetDefault("PASSWORD_REQUIRE_UPPERCASE", true)
+	v.SetDefault("PASSWORD_REQUIRE_LOWERCASE", true)
+	v.SetDefault("PASSWORD_REQUIRE_NUMBERS", true)
+	v.SetDefault("PASSWORD_REQUIRE_SYMBOLS", true)
+	v.SetDefault("MAX_REQUEST_BODY_BYTES", 64<<10)
+	v.SetDefault("LOGIN_LOCKOUT_MAX_FAILURES", 5)
+	v.SetDefault("LOGIN_LOCKOUT_IP_MAX_FAILURES", 50)
+	v.SetDefault("LOGIN_LOCKOUT_WINDOW", "15m")
+	v.SetDefault("LOGIN_LOCKOUT_DURATION", "15m")
+	v.SetDefault("LOGIN_BACKOFF_BASE", "1s")
+	v.SetDefault("LOGIN_BACKOFF_MAX", "30s")
+	for route, limits := range rateLimitDefaults {
+		v.SetDefault(rateLimitKey(route, "IP"), limits[0])
+		v.SetDefault(rateLimitKey(route, "EMAIL"), limits[1])
+	}
 
 	v.SetConfigFile(".env")
 	// v.SetConfigFile("../../.env")
@@ -36,21 +169,176 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
-	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	tokenUse := v.GetString("TOKEN_USE")
+	if tokenUse != TokenUseAccess && tokenUse != TokenUseID && tokenUse != TokenUseBoth {
+		return nil, fmt.Errorf("invalid TOKEN_USE %q, expected access, id or both", tokenUse)
+	}
+
+	rateLimits := make(map[string]RouteRateLimits, len(rateLimitDefaults))
+	for route := range rateLimitDefaults {
+		ip, err := parseRateLimit(v.GetString(rateLimitKey(route, "IP")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", rateLimitKey(route, "IP"), err)
+		}
+		email, err := parseRateLimit(v.GetString(rateLimitKey(route, "EMAIL")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", rateLimitKey(route, "EMAIL"), err)
+		}
+		rateLimits[route] = RouteRateLimits{IP: ip, Email: email}
+	}
+
+	cognitoClient := CognitoClient{
+		EndpointURL:           v.GetString("AWS_COGNITO_ENDPOINT_URL"),
+		Region:                v.GetString("AWS_COGNITO_REGION"),
+		Profile:               v.GetString("AWS_COGNITO_PROFILE"),
+		RetryMaxAttempts:      v.GetInt("AWS_COGNITO_RETRY_MAX_ATTEMPTS"),
+		HTTPTimeout:           v.GetDuration("AWS_COGNITO_HTTP_TIMEOUT"),
+		ConnectTimeout:        v.GetDuration("AWS_COGNITO_CONNECT_TIMEOUT"),
+		ResponseHeaderTimeout: v.GetDuration("AWS_COGNITO_RESPONSE_HEADER_TIMEOUT"),
+	}
+	if cognitoClient.EndpointURL != "" {
+		endpoint, err := url.Parse(cognitoClient.EndpointURL)
+		if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid AWS_COGNITO_ENDPOINT_URL %q, expected an absolute URL", cognitoClient.EndpointURL)
+		}
+	}
+	if mode := v.GetString("AWS_COGNITO_RETRY_MODE"); mode != "" {
+		retryMode, err := aws.ParseRetryMode(mode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AWS_COGNITO_RETRY_MODE %q, expected standard or adaptive", mode)
+		}
+		cognitoClient.RetryMode = retryMode
+	}
+	if cognitoClient.RetryMaxAttempts < 0 {
+		return nil, fmt.Errorf("invalid AWS_COGNITO_RETRY_MAX_ATTEMPTS %d", cognitoClient.RetryMaxAttempts)
+	}
+
+	var awsOpts []func(*awsconfig.LoadOptions) error
+	if cognitoClient.Region != "" {
+		awsOpts = append(awsOpts, awsconfig.WithRegion(cognitoClient.Region))
+	}
+	if cognitoClient.Profile != "" {
+		awsOpts = append(awsOpts, awsconfig.WithSharedConfigProfile(cognitoClient.Profile))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), awsOpts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS configuration")
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
 	}
 
 	cfg := &Config{
-		Env:                    v.GetString("ENV"),
-		Port:                   v.GetString("PORT"),
-		AwsCognitoUserPoolId:   v.GetString("AWS_COGNITO_USER_POOL_ID"),
-		AwsCognitoClientId:     v.GetString("AWS_COGNITO_CLIENT_ID"),
-		AwsCognitoClientSecret: v.GetString("AWS_COGNITO_CLIENT_SECRET"),
-		AwsConfig:              awsCfg,
-		AwsTokenURL:            v.GetString("AWS_COGNITO_TOKEN_URL"),
-		AwsJWTIssuerURL:        v.GetString("AWS_COGNITO_JWT_ISSUER_URL"),
+		Env:                        v.GetString("ENV"),
+		Port:                       v.GetString("PORT"),
+		AuthBackend:                v.GetString("AUTH_BACKEND"),
+		AwsCognitoUserPoolId:       v.GetString("AWS_COGNITO_USER_POOL_ID"),
+		AwsCognitoClientId:         v.GetString("AWS_COGNITO_CLIENT_ID"),
+		AwsCognitoClientSecret:     v.GetString("AWS_COGNITO_CLIENT_SECRET"),
+		AwsCognitoAllowedClientIds: splitList(v.GetString("AWS_COGNITO_ALLOWED_CLIENT_IDS")),
+		AwsConfig:                  awsCfg,
+		CognitoClient:              cognitoClient,
+		AwsTokenURL:                v.GetString("AWS_COGNITO_TOKEN_URL"),
+		AwsJWTIssuerURL:            v.GetString("AWS_COGNITO_JWT_ISSUER_URL"),
+		MfaTotpIssuer:              v.GetString("MFA_TOTP_ISSUER"),
+		JwksRefreshInterval:        v.GetDuration("JWKS_REFRESH_INTERVAL"),
+		JwksMinRefetchInterval:     v.GetDuration("JWKS_MIN_REFETCH_INTERVAL"),
+		TokenUse:                   tokenUse,
+		TokenLeeway:                v.GetDuration("TOKEN_LEEWAY"),
+		AdminGroup:                 v.GetString("ADMIN_GROUP"),
+		PasswordPolicy: PasswordPolicy{
+			MinLength:        v.GetInt("PASSWORD_MIN_LENGTH"),
+			RequireUppercase: v.GetBool("PASSWORD_REQUIRE_UPPERCASE"),
+			RequireLowercase: v.GetBool("PASSWORD_REQUIRE_LOWERCASE"),
+			RequireNumbers:   v.GetBool("PASSWORD_REQUIRE_NUMBERS"),
+			RequireSymbols:   v.GetBool("PASSWORD_REQUIRE_SYMBOLS"),
+		},
+		MaxRequestBodyBytes: v.GetInt64("MAX_REQUEST_BODY_BYTES"),
+		RateLimits:          rateLimits,
+		LoginLockout: LoginLockout{
+			MaxFailures:     v.GetInt("LOGIN_LOCKOUT_MAX_FAILURES"),
+			IPMaxFailures:   v.GetInt("LOGIN_LOCKOUT_IP_MAX_FAILURES"),
+			Window:          v.GetDuration("LOGIN_LOCKOUT_WINDOW"),
+			LockoutDuration: v.GetDuration("LOGIN_LOCKOUT_DURATION"),
+			BackoffBase:     v.GetDuration("LOGIN_BACKOFF_BASE"),
+			BackoffMax:      v.GetDuration("LOGIN_BACKOFF_MAX"),
+		},
+	}
+
+	if cfg.AuthBackend == "" || cfg.AuthBackend == "cognito" {
+		if err := cfg.resolveCognitoURLs(); err != nil {
+			return nil, err
+		}
 	}
 
 	return cfg, nil
 }
+
+// resolveCognitoURLs fills in the issuer and JWKS URLs of the user pool when
+// they aren't set, deriving them from the region and pool ID the way Cognito
+// builds them. The JWKS URL follows an overridden issuer. It fails when the
+// pool ID doesn't belong to the configured region, which would otherwise only
+// show up as every token being rejected.
+func (c *Config) resolveCognitoURLs() error {
+	if c.AwsCognitoUserPoolId == "" {
+		return fmt.Errorf("AWS_COGNITO_USER_POOL_ID is required")
+	}
+	poolRegion, _, ok := strings.Cut(c.AwsCognitoUserPoolId, "_")
+	if !ok || poolRegion == "" {
+		return fmt.Errorf("invalid AWS_COGNITO_USER_POOL_ID %q, expected <region>_<id>", c.AwsCognitoUserPoolId)
+	}
+
+	region := c.CognitoClient.Region
+	if region == "" {
+		region = c.AwsConfig.Region
+	}
+	if region == "" {
+		return fmt.Errorf("no AWS region configured, set AWS_COGNITO_REGION to %s", poolRegion)
+	}
+	if region != poolRegion {
+		return fmt.Errorf("user pool %s is in %s but the configured region is %s", c.AwsCognitoUserPoolId, poolRegion, region)
+	}
+
+	if c.AwsJWTIssuerURL == "" {
+		c.AwsJWTIssuerURL = fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, c.AwsCognitoUserPoolId)
+	}
+	if c.AwsTokenURL == "" {
+		c.AwsTokenURL = strings.TrimSuffix(c.AwsJWTIssuerURL, "/") + "/.well-known/jwks.json"
+	}
+	return nil
+}
+
+// splitList reads a comma separated value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func rateLimitKey(route, by string) string {
+	return "RATE_LIMIT_" + strings.ToUpper(route) + "_" + by
+}
+
+// parseRateLimit reads a limit written as requests/period, such as 5/1m.
+// "off" and "0" turn the limit off.
+func parseRateLimit(value string) (RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "off" || value == "0" {
+		return RateLimit{}, nil
+	}
+
+	requests, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("%q is not requests/period", value)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 0 {
+		return RateLimit{}, fmt.Errorf("%q has an invalid request count", value)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("%q has an invalid period", value)
+	}
+	return RateLimit{Requests: n, Period: d}, nil
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestResolveCognitoURLs(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        Config
+		wantIssuer string
+		wantJWKS   string
+		wantErr    string
+	}{
+		{
+			name: "derived from the SDK region",
+			cfg: Config{
+				AwsCognitoUserPoolId: "eu-west-1_AbC123",
+				AwsConfig:            aws.Config{Region: "eu-west-1"},
+			},
+			wantIssuer: "https://cognito-idp.eu-west-1.amazonaws.com/eu-west-1_AbC123",
+			wantJWKS:   "https://cognito-idp.eu-west-1.amazonaws.com/eu-west-1_AbC123/.well-known/jwks.json",
+		},
+		{
+			name: "derived from AWS_COGNITO_REGION",
+			cfg: Config{
+				AwsCognitoUserPoolId: "us-east-1_AbC123",
+				AwsConfig:            aws.Config{Region: "eu-west-1"},
+				CognitoClient:        CognitoClient{Region: "us-east-1"},
+			},
+			wantIssuer: "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_AbC123",
+			wantJWKS:   "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_AbC123/.well-known/jwks.json",
+		},
+		{
+			name: "JWKS follows an overridden issuer",
+			cfg: Config{
+				AwsCognitoUserPoolId: "us-east-1_local",
+				AwsConfig:            aws.Config{Region: "us-east-1"},
+				AwsJWTIssuerURL:      "http://localhost:9229/us-east-1_local/",
+			},
+			wantIssuer: "http://localhost:9229/us-east-1_local/",
+			wantJWKS:   "http://localhost:9229/us-east-1_local/.well-known/jwks.json",
+		},
+		{
+			name: "both overridden",
+			cfg: Config{
+				AwsCognitoUserPoolId: "us-east-1_AbC123",
+				AwsConfig:            aws.Config{Region: "us-east-1"},
+				AwsJWTIssuerURL:      "https://issuer.example.com",
+				AwsTokenURL:          "https://keys.example.com/jwks.json",
+			},
+			wantIssuer: "https://issuer.example.com",
+			wantJWKS:   "https://keys.example.com/jwks.json",
+		},
+		{
+			name: "region mismatch",
+			cfg: Config{
+				AwsCognitoUserPoolId: "us-east-1_AbC123",
+				AwsConfig:            aws.Config{Region: "eu-west-1"},
+			},
+			wantErr: "is in us-east-1 but the configured region is eu-west-1",
+		},
+		{
+			name: "region mismatch with overridden URLs",
+			cfg: Config{
+				AwsCognitoUserPoolId: "us-east-1_AbC123",
+				CognitoClient:        CognitoClient{Region: "eu-west-1"},
+				AwsJWTIssuerURL:      "https://issuer.example.com",
+				AwsTokenURL:          "https://keys.example.com/jwks.json",
+			},
+			wantErr: "is in us-east-1 but the configured region is eu-west-1",
+		},
+		{
+			name:    "no region",
+			cfg:     Config{AwsCognitoUserPoolId: "us-east-1_AbC123"},
+			wantErr: "no AWS region configured",
+		},
+		{
+			name:    "no pool ID",
+			cfg:     Config{AwsConfig: aws.Config{Region: "us-east-1"}},
+			wantErr: "AWS_COGNITO_USER_POOL_ID is required",
+		},
+		{
+			name:    "pool ID without region",
+			cfg:     Config{AwsCognitoUserPoolId: "AbC123", AwsConfig: aws.Config{Region: "us-east-1"}},
+			wantErr: "expected <region>_<id>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.resolveCognitoURLs()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+			if tt.cfg.AwsJWTIssuerURL != tt.wantIssuer {
+				t.Errorf("issuer = %q, want %q", tt.cfg.AwsJWTIssuerURL, tt.wantIssuer)
+			}
+			if tt.cfg.AwsTokenURL != tt.wantJWKS {
+				t.Errorf("JWKS URL = %q, want %q", tt.cfg.AwsTokenURL, tt.wantJWKS)
+			}
+		})
+	}
+}
@@ -1,10 +1,35 @@
 package config
 
 import (
+	"context"
 	"log/slog"
 	"os"
+
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 func init() {
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	slog.SetDefault(slog.New(&requestIDHandler{slog.NewJSONHandler(os.Stdout, nil)}))
+}
+
+// requestIDHandler adds the ID of the request being served to records logged
+// with its context, so server-side details can be matched with the
+// request_id clients see in error responses.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h *requestIDHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := middleware.GetReqID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *requestIDHandler) WithGroup(name string) slog.Handler {
+	return &requestIDHandler{h.Handler.WithGroup(name)}
 }
@@ -11,29 +11,73 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/lestrrat-go/jwx/v2/jwk"
 )
 
 type CognitoStore struct {
-	client       *cognitoidentityprovider.Client
+	client       CognitoClient
 	userPoolId   string
 	clientId     string
 	clientSecret string
 	tokenURL     string
 	jwtIssuerURL string
-	jwkSet       jwk.Set
+	mfaIssuer    string
+	jwks         *jwksProvider
+	validator    *tokenValidator
 }
 
 func NewCognitoStore(cfg *config.Config) (*CognitoStore, error) {
-	keySet, err := jwk.Fetch(context.Background(), cfg.AwsTokenURL)
+	client := cognitoidentityprovider.NewFromConfig(cfg.AwsConfig, cognitoClientOptions(cfg.CognitoClient))
+	return NewCognitoStoreWithClient(cfg, client)
+}
+
+// cognitoClientOptions applies the overrides of opts on top of the AWS
+// configuration the client is built from.
+func cognitoClientOptions(opts config.CognitoClient) func(*cognitoidentityprovider.Options) {
+	return func(o *cognitoidentityprovider.Options) {
+		if opts.EndpointURL != "" {
+			o.BaseEndpoint = aws.String(opts.EndpointURL)
+		}
+		if opts.Region != "" {
+			o.Region = opts.Region
+		}
+		if opts.RetryMode != "" {
+			o.RetryMode = opts.RetryMode
+		}
+		if opts.RetryMaxAttempts > 0 {
+			o.RetryMaxAttempts = opts.RetryMaxAttempts
+		}
+		if opts.HTTPTimeout > 0 || opts.ConnectTimeout > 0 || opts.ResponseHeaderTimeout > 0 {
+			o.HTTPClient = awshttp.NewBuildableClient().
+				WithTimeout(opts.HTTPTimeout).
+				WithDialerOptions(func(d *net.Dialer) {
+					if opts.ConnectTimeout > 0 {
+						d.Timeout = opts.ConnectTimeout
+					}
+				}).
+				WithTransportOptions(func(t *http.Transport) {
+					if opts.ResponseHeaderTimeout > 0 {
+						t.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+					}
+				})
+		}
+	}
+}
+
+// NewCognitoStoreWithClient is NewCognitoStore with the Cognito client given
+// instead of built from cfg.AwsConfig.
+func NewCognitoStoreWithClient(cfg *config.Config, client CognitoClient) (*CognitoStore, error) {
+	jwks, err := newJWKSProvider(cfg.AwsTokenURL, cfg.JwksRefreshInterval, cfg.JwksMinRefetchInterval)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch JWK set: %w", err)
+		return nil, err
 	}
 	return &CognitoStore{
 		userPoolId:   cfg.AwsCognitoUserPoolId,
@@ -41,25 +85,23 @@ func NewCognitoStore(cfg *config.Config) (*CognitoStore, error) {
 		clientSecret: cfg.AwsCognitoClientSecret,
 		tokenURL:     cfg.AwsTokenURL,
 		jwtIssuerURL: cfg.AwsJWTIssuerURL,
-		client:       cognitoidentityprovider.NewFromConfig(cfg.AwsConfig),
-		jwkSet:       keySet,
+		mfaIssuer:    cfg.MfaTotpIssuer,
+		client:       client,
+		jwks:         jwks,
+		validator:    newTokenValidator(cfg, cfg.AwsJWTIssuerURL, cfg.AwsCognitoClientId),
 	}, nil
 }
 
 func (s *CognitoStore) ValidateToken(tokenString string) (*jwt.Token, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
+	return s.validator.validate(tokenString, func(token *jwt.Token) (interface{}, error) {
 		kid, ok := token.Header["kid"].(string)
 		if !ok {
 			return nil, errors.New("key ID not found in token")
 		}
 
-		key, found := s.jwkSet.LookupKeyID(kid)
-		if !found {
-			return nil, errors.New("key not found in JWKS")
+		key, err := s.jwks.lookupKeyID(kid)
+		if err != nil {
+			return nil, err
 		}
 
 		var rawKey interface{}
@@ -69,33 +111,10 @@ func (s *CognitoStore) ValidateToken(tokenString string) (*jwt.Token, error) {
 
 		return rawKey, nil
 	})
-	if err != nil {
-		return nil, fmt.Errorf("invalid token: %w", err)
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return nil, errors.New("invalid token claims")
-	}
-
-	issuer, err := claims.GetIssuer()
-	if err != nil {
-		return nil, errors.New("token has invalid issuer")
-	}
-
-	if strings.Compare(issuer, s.jwtIssuerURL) != 0 {
-		return nil, errors.New("token was not issued by the specified Cognito user pool")
-	}
-
-	return token, nil
 }
 
-func (s *CognitoStore) GetClaims(token *jwt.Token) (map[string]interface{}, error) {
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, errors.New("invalid token claims")
-	}
-	return claims, nil
+func (s *CognitoStore) GetClaims(token *jwt.Token) (*models.Claims, error) {
+	return claimsFromToken(token)
 }
 
 func (s *CognitoStore) GetUser(ctx context.Context, token string) (*models.UserInfoResponse, error) {
@@ -109,7 +128,7 @@ func (s *CognitoStore) GetUser(ctx context.Context, token string) (*models.UserI
 		var notAuthErr *types.NotAuthorizedException
 
 		if errors.As(err, &forbiddenErr) || errors.As(err, &invalidParamErr) || errors.As(err, &notAuthErr) {
-			return nil, appError.NewInvalidInputError(err.Error())
+			return nil, appError.NewInvalidInputError("Invalid access token").WithCause(err)
 		}
 
 		slog.ErrorContext(ctx, "Failed to get user info", "err", err)
@@ -134,14 +153,11 @@ func (s *CognitoStore) GetUser(ctx context.Context, token string) (*models.UserI
 
 func (s *CognitoStore) SignUp(ctx context.Context, user *models.User) error {
 	input := &cognitoidentityprovider.SignUpInput{
-		ClientId: aws.String(s.clientId),
-		Password: aws.String(user.Password),
-		Username: aws.String(user.Email),
-		UserAttributes: []types.AttributeType{
-			{Name: aws.String("name"), Value: aws.String(user.Name)},
-			{Name: aws.String("email"), Value: aws.String(user.Email)},
-		},
-		SecretHash: aws.String(s.generateSecretHash(user.Email)),
+		ClientId:       aws.String(s.clientId),
+		Password:       aws.String(user.Password),
+		Username:       aws.String(user.Email),
+		UserAttributes: attributeTypes(signUpAttributes(user)),
+		SecretHash:     aws.String(s.generateSecretHash(user.Email)),
 	}
 
 	_, err := s.client.SignUp(ctx, input)
@@ -153,9 +169,9 @@ func (s *CognitoStore) SignUp(ctx context.Context, user *models.User) error {
 		if errors.As(err, &usernameExistsErr) {
 			return appError.NewAccountExistsError()
 		} else if errors.As(err, &invalidPasswordErr) {
-			return appError.NewInvalidInputError(err.Error())
+			return appError.NewInvalidPasswordError("").WithCause(err)
 		} else if errors.As(err, &invalidParamErr) {
-			return appError.NewInvalidInputError(err.Error())
+			return appError.NewInvalidInputError("").WithCause(err)
 		}
 
 		slog.ErrorContext(ctx, "Failed to sign up user", "email", user.Email, "err", err)
@@ -194,6 +210,35 @@ func (s *CognitoStore) ConfirmAccount(ctx context.Context, user *models.UserConf
 	return nil
 }
 
+func (s *CognitoStore) ResendConfirmationCode(ctx context.Context, params *models.UserResendCodeParams) error {
+	_, err := s.client.ResendConfirmationCode(ctx, &cognitoidentityprovider.ResendConfirmationCodeInput{
+		ClientId:   aws.String(s.clientId),
+		Username:   aws.String(params.Email),
+		SecretHash: aws.String(s.generateSecretHash(params.Email)),
+	})
+
+	if err != nil {
+		var notFoundErr *types.UserNotFoundException
+		var invalidParamErr *types.InvalidParameterException
+		var limitExceededErr *types.LimitExceededException
+
+		switch {
+		case errors.As(err, &notFoundErr):
+			return nil
+		case errors.As(err, &invalidParamErr):
+			// Cognito raises this when the user is already confirmed.
+			return appError.NewInvalidInputError("Account already confirmed")
+		case errors.As(err, &limitExceededErr):
+			return appError.NewTooManyRequestsError("Please try again later")
+		default:
+			slog.ErrorContext(ctx, "Failed to resend confirmation code", "email", params.Email, "err", err)
+			return appError.NewServiceUnavailableError("Unable to resend confirmation code")
+		}
+	}
+
+	return nil
+}
+
 func (s *CognitoStore) Login(ctx context.Context, user *models.UserLoginParams) (*models.AuthLoginResponse, error) {
 	output, err := s.client.InitiateAuth(ctx, &cognitoidentityprovider.InitiateAuthInput{
 		AuthFlow:       types.AuthFlowTypeUserPasswordAuth,
@@ -222,18 +267,258 @@ func (s *CognitoStore) Login(ctx context.Context, user *models.UserLoginParams)
 		}
 	}
 
+	return authResponse(output.AuthenticationResult, output.ChallengeName, output.Session, output.ChallengeParameters)
+}
+
+func (s *CognitoStore) RespondToChallenge(ctx context.Context, params *models.UserChallengeParams) (*models.AuthLoginResponse, error) {
+	responses := map[string]string{
+		"USERNAME":    params.Username,
+		"SECRET_HASH": s.generateSecretHash(params.Username),
+	}
+
+	challengeName := types.ChallengeNameType(params.ChallengeName)
+	switch challengeName {
+	case types.ChallengeNameTypeNewPasswordRequired:
+		responses["NEW_PASSWORD"] = params.NewPassword
+		for name, value := range params.Attributes {
+			responses["userAttributes."+name] = value
+		}
+	case types.ChallengeNameTypeSmsMfa:
+		responses["SMS_MFA_CODE"] = params.Code
+	case types.ChallengeNameTypeSoftwareTokenMfa:
+		responses["SOFTWARE_TOKEN_MFA_CODE"] = params.Code
+	case types.ChallengeNameTypeSelectMfaType:
+		responses["ANSWER"] = params.MFAType
+	default:
+		return nil, appError.NewInvalidInputError("Unsupported challenge")
+	}
+
+	output, err := s.client.RespondToAuthChallenge(ctx, &cognitoidentityprovider.RespondToAuthChallengeInput{
+		ChallengeName:      challengeName,
+		ClientId:           aws.String(s.clientId),
+		ChallengeResponses: responses,
+		Session:            aws.String(params.Session),
+	})
+
+	if err != nil {
+		var codeMismatchErr *types.CodeMismatchException
+		var expiredCodeErr *types.ExpiredCodeException
+		var invalidPasswordErr *types.InvalidPasswordException
+		var invalidParamErr *types.InvalidParameterException
+		var notAuthErr *types.NotAuthorizedException
+		var userNotFoundErr *types.UserNotFoundException
+		var tooManyAttemptsErr *types.TooManyFailedAttemptsException
+		var limitExceededErr *types.LimitExceededException
+
+		switch {
+		case errors.As(err, &codeMismatchErr):
+			return nil, appError.NewInvalidCodeError("")
+		case errors.As(err, &expiredCodeErr):
+			return nil, appError.NewExpiredCodeError()
+		case errors.As(err, &invalidPasswordErr):
+			return nil, appError.NewInvalidPasswordError("").WithCause(err)
+		case errors.As(err, &invalidParamErr):
+			return nil, appError.NewInvalidInputError("").WithCause(err)
+		case errors.As(err, &notAuthErr), errors.As(err, &userNotFoundErr):
+			// Also covers an expired session, which forces a fresh login.
+			return nil, appError.NewInvalidCredentialsError("")
+		case errors.As(err, &tooManyAttemptsErr), errors.As(err, &limitExceededErr):
+			return nil, appError.NewTooManyRequestsError("Please try again later")
+		default:
+			slog.ErrorContext(ctx, "Failed to respond to auth challenge", "challenge", params.ChallengeName, "err", err)
+			return nil, appError.NewServiceUnavailableError("Authentication service unavailable")
+		}
+	}
+
+	return authResponse(output.AuthenticationResult, output.ChallengeName, output.Session, output.ChallengeParameters)
+}
+
+// authResponse turns the result of InitiateAuth or RespondToAuthChallenge into
+// either issued tokens or the next challenge the user has to answer.
+func authResponse(result *types.AuthenticationResultType, challenge types.ChallengeNameType, session *string, parameters map[string]string) (*models.AuthLoginResponse, error) {
+	if result != nil {
+		return models.NewAuthLoginResponse(
+			aws.ToString(result.AccessToken),
+			aws.ToString(result.RefreshToken),
+			int(result.ExpiresIn),
+		), nil
+	}
+
+	if challenge != "" {
+		return models.NewAuthChallengeResponse(string(challenge), aws.ToString(session), parameters), nil
+	}
+
+	return nil, appError.NewServiceUnavailableError("Invalid authentication result")
+}
+
+func (s *CognitoStore) Refresh(ctx context.Context, params *models.UserRefreshParams) (*models.AuthLoginResponse, error) {
+	output, err := s.client.InitiateAuth(ctx, &cognitoidentityprovider.InitiateAuthInput{
+		AuthFlow:       types.AuthFlowTypeRefreshTokenAuth,
+		ClientId:       aws.String(s.clientId),
+		AuthParameters: map[string]string{"REFRESH_TOKEN": params.RefreshToken, "SECRET_HASH": s.generateSecretHash(params.Username)},
+	})
+
+	if err != nil {
+		var notAuthErr *types.NotAuthorizedException
+		var userNotFoundErr *types.UserNotFoundException
+
+		switch {
+		case errors.As(err, &notAuthErr):
+			// Cognito reports expired, revoked and malformed refresh tokens
+			// through the same exception, only the message tells them apart.
+			msg := strings.ToLower(notAuthErr.ErrorMessage())
+			switch {
+			case strings.Contains(msg, "expired"):
+				return nil, appError.NewExpiredRefreshTokenError()
+			case strings.Contains(msg, "revoked"):
+				return nil, appError.NewRevokedRefreshTokenError()
+			default:
+				return nil, appError.NewInvalidRefreshTokenError()
+			}
+		case errors.As(err, &userNotFoundErr):
+			return nil, appError.NewInvalidRefreshTokenError()
+		default:
+			slog.ErrorContext(ctx, "Failed to refresh session", "username", params.Username, "err", err)
+			return nil, appError.NewServiceUnavailableError("Authentication service unavailable")
+		}
+	}
+
 	authResult := output.AuthenticationResult
 	if authResult == nil {
 		return nil, appError.NewServiceUnavailableError("Invalid authentication result")
 	}
 
+	// Cognito only returns a new refresh token when rotation is enabled on the
+	// app client, otherwise the one the caller sent stays valid.
+	refreshToken := aws.ToString(authResult.RefreshToken)
+	if refreshToken == "" {
+		refreshToken = params.RefreshToken
+	}
+
 	return models.NewAuthLoginResponse(
 		aws.ToString(authResult.AccessToken),
-		aws.ToString(authResult.RefreshToken),
+		refreshToken,
 		int(authResult.ExpiresIn),
 	), nil
 }
 
+func (s *CognitoStore) RevokeToken(ctx context.Context, refreshToken string) error {
+	_, err := s.client.RevokeToken(ctx, &cognitoidentityprovider.RevokeTokenInput{
+		ClientId:     aws.String(s.clientId),
+		ClientSecret: aws.String(s.clientSecret),
+		Token:        aws.String(refreshToken),
+	})
+
+	if err != nil {
+		var unauthorizedErr *types.UnauthorizedException
+		var unsupportedErr *types.UnsupportedTokenTypeException
+		var invalidParamErr *types.InvalidParameterException
+		var tooManyRequestsErr *types.TooManyRequestsException
+
+		switch {
+		case errors.As(err, &unauthorizedErr), errors.As(err, &unsupportedErr), errors.As(err, &invalidParamErr):
+			return appError.NewInvalidRefreshTokenError()
+		case errors.As(err, &tooManyRequestsErr):
+			return appError.NewTooManyRequestsError("Please try again later")
+		default:
+			slog.ErrorContext(ctx, "Failed to revoke refresh token", "err", err)
+			return appError.NewServiceUnavailableError("Unable to log out")
+		}
+	}
+
+	return nil
+}
+
+func (s *CognitoStore) GlobalSignOut(ctx context.Context, token string) error {
+	_, err := s.client.GlobalSignOut(ctx, &cognitoidentityprovider.GlobalSignOutInput{
+		AccessToken: aws.String(token),
+	})
+
+	if err != nil {
+		var notAuthErr *types.NotAuthorizedException
+		var tooManyRequestsErr *types.TooManyRequestsException
+
+		switch {
+		case errors.As(err, &notAuthErr):
+			return appError.NewInvalidCredentialsError("Session is no longer valid")
+		case errors.As(err, &tooManyRequestsErr):
+			return appError.NewTooManyRequestsError("Please try again later")
+		default:
+			slog.ErrorContext(ctx, "Failed to sign out user globally", "err", err)
+			return appError.NewServiceUnavailableError("Unable to log out")
+		}
+	}
+
+	return nil
+}
+
+func (s *CognitoStore) ForgotPassword(ctx context.Context, params *models.UserForgotPasswordParams) error {
+	_, err := s.client.ForgotPassword(ctx, &cognitoidentityprovider.ForgotPasswordInput{
+		ClientId:   aws.String(s.clientId),
+		Username:   aws.String(params.Email),
+		SecretHash: aws.String(s.generateSecretHash(params.Email)),
+	})
+
+	if err != nil {
+		var notFoundErr *types.UserNotFoundException
+		var invalidParamErr *types.InvalidParameterException
+		var limitExceededErr *types.LimitExceededException
+
+		switch {
+		case errors.As(err, &notFoundErr):
+			// Answer the same way as for a known user so the endpoint can't
+			// be used to find out which emails are registered.
+			return nil
+		case errors.As(err, &invalidParamErr):
+			return appError.NewInvalidInputError("Account has no verified email")
+		case errors.As(err, &limitExceededErr):
+			return appError.NewTooManyRequestsError("Please try again later")
+		default:
+			slog.ErrorContext(ctx, "Failed to start password reset", "email", params.Email, "err", err)
+			return appError.NewServiceUnavailableError("Unable to start password reset")
+		}
+	}
+
+	return nil
+}
+
+func (s *CognitoStore) ConfirmForgotPassword(ctx context.Context, params *models.UserResetPasswordParams) error {
+	_, err := s.client.ConfirmForgotPassword(ctx, &cognitoidentityprovider.ConfirmForgotPasswordInput{
+		ClientId:         aws.String(s.clientId),
+		ConfirmationCode: aws.String(params.Code),
+		Password:         aws.String(params.Password),
+		Username:         aws.String(params.Email),
+		SecretHash:       aws.String(s.generateSecretHash(params.Email)),
+	})
+
+	if err != nil {
+		var codeMismatchErr *types.CodeMismatchException
+		var expiredCodeErr *types.ExpiredCodeException
+		var invalidPasswordErr *types.InvalidPasswordException
+		var notFoundErr *types.UserNotFoundException
+		var limitExceededErr *types.LimitExceededException
+		var tooManyAttemptsErr *types.TooManyFailedAttemptsException
+
+		switch {
+		case errors.As(err, &codeMismatchErr):
+			return appError.NewInvalidCodeError("")
+		case errors.As(err, &expiredCodeErr):
+			return appError.NewExpiredCodeError()
+		case errors.As(err, &invalidPasswordErr):
+			return appError.NewInvalidPasswordError("").WithCause(err)
+		case errors.As(err, &notFoundErr):
+			return appError.NewInvalidCodeError("")
+		case errors.As(err, &limitExceededErr), errors.As(err, &tooManyAttemptsErr):
+			return appError.NewTooManyRequestsError("Please try again later")
+		default:
+			slog.ErrorContext(ctx, "Failed to reset password", "email", params.Email, "err", err)
+			return appError.NewServiceUnavailableError("Unable to reset password")
+		}
+	}
+
+	return nil
+}
+
 func (s *CognitoStore) generateSecretHash(username string) string {
 	h := hmac.New(sha256.New, []byte(s.clientSecret))
 	h.Write([]byte(username + s.clientId))
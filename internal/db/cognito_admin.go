@@ -0,0 +1,320 @@
+package db
+
+import (
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+func (s *CognitoStore) ListUsers(ctx context.Context, params *models.ListUsersParams) (*models.ListUsersResponse, error) {
+	input := &cognitoidentityprovider.ListUsersInput{
+		UserPoolId: aws.String(s.userPoolId),
+	}
+	if params.Limit > 0 {
+		input.Limit = aws.Int32(int32(params.Limit))
+	}
+	if params.PaginationToken != "" {
+		input.PaginationToken = aws.String(params.PaginationToken)
+	}
+	if params.FilterAttribute != "" {
+		input.Filter = aws.String(cognitoFilter(params))
+	}
+
+	output, err := s.client.ListUsers(ctx, input)
+	if err != nil {
+		return nil, mapAdminError(ctx, err, "Unable to list users")
+	}
+
+	users := make([]models.AdminUser, 0, len(output.Users))
+	for _, user := range output.Users {
+		users = append(users, models.AdminUser{
+			Username:   aws.ToString(user.Username),
+			Attributes: attributeMap(user.Attributes),
+			Enabled:    user.Enabled,
+			Status:     string(user.UserStatus),
+			CreatedAt:  aws.ToTime(user.UserCreateDate),
+			UpdatedAt:  aws.ToTime(user.UserLastModifiedDate),
+		})
+	}
+
+	return &models.ListUsersResponse{
+		Users:           users,
+		PaginationToken: aws.ToString(output.PaginationToken),
+	}, nil
+}
+
+func (s *CognitoStore) AdminGetUser(ctx context.Context, username string) (*models.AdminUser, error) {
+	output, err := s.client.AdminGetUser(ctx, &cognitoidentityprovider.AdminGetUserInput{
+		UserPoolId: aws.String(s.userPoolId),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return nil, mapAdminError(ctx, err, "Unable to fetch user")
+	}
+
+	return &models.AdminUser{
+		Username:   aws.ToString(output.Username),
+		Attributes: attributeMap(output.UserAttributes),
+		Enabled:    output.Enabled,
+		Status:     string(output.UserStatus),
+		CreatedAt:  aws.ToTime(output.UserCreateDate),
+		UpdatedAt:  aws.ToTime(output.UserLastModifiedDate),
+	}, nil
+}
+
+func (s *CognitoStore) AdminDisableUser(ctx context.Context, username string) error {
+	_, err := s.client.AdminDisableUser(ctx, &cognitoidentityprovider.AdminDisableUserInput{
+		UserPoolId: aws.String(s.userPoolId),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return mapAdminError(ctx, err, "Unable to disable user")
+	}
+	return nil
+}
+
+func (s *CognitoStore) AdminEnableUser(ctx context.Context, username string) error {
+	_, err := s.client.AdminEnableUser(ctx, &cognitoidentityprovider.AdminEnableUserInput{
+		UserPoolId: aws.String(s.userPoolId),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return mapAdminError(ctx, err, "Unable to enable user")
+	}
+	return nil
+}
+
+func (s *CognitoStore) AdminDeleteUser(ctx context.Context, username string) error {
+	_, err := s.client.AdminDeleteUser(ctx, &cognitoidentityprovider.AdminDeleteUserInput{
+		UserPoolId: aws.String(s.userPoolId),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return mapAdminError(ctx, err, "Unable to delete user")
+	}
+	return nil
+}
+
+func (s *CognitoStore) AdminConfirmSignUp(ctx context.Context, username string) error {
+	_, err := s.client.AdminConfirmSignUp(ctx, &cognitoidentityprovider.AdminConfirmSignUpInput{
+		UserPoolId: aws.String(s.userPoolId),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		var notAuthErr *types.NotAuthorizedException
+		if errors.As(err, &notAuthErr) {
+			// Cognito refuses to confirm a user that is not UNCONFIRMED.
+			return appError.NewInvalidInputError("Account already confirmed")
+		}
+		return mapAdminError(ctx, err, "Unable to confirm user")
+	}
+	return nil
+}
+
+func (s *CognitoStore) AdminResetUserPassword(ctx context.Context, username string) error {
+	_, err := s.client.AdminResetUserPassword(ctx, &cognitoidentityprovider.AdminResetUserPasswordInput{
+		UserPoolId: aws.String(s.userPoolId),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return mapAdminError(ctx, err, "Unable to reset password")
+	}
+	return nil
+}
+
+func (s *CognitoStore) AdminUserGlobalSignOut(ctx context.Context, username string) error {
+	_, err := s.client.AdminUserGlobalSignOut(ctx, &cognitoidentityprovider.AdminUserGlobalSignOutInput{
+		UserPoolId: aws.String(s.userPoolId),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return mapAdminError(ctx, err, "Unable to sign out user")
+	}
+	return nil
+}
+
+func (s *CognitoStore) ListGroups(ctx context.Context, params *models.ListGroupsParams) (*models.ListGroupsResponse, error) {
+	input := &cognitoidentityprovider.ListGroupsInput{
+		UserPoolId: aws.String(s.userPoolId),
+	}
+	if params.Limit > 0 {
+		input.Limit = aws.Int32(int32(params.Limit))
+	}
+	if params.NextToken != "" {
+		input.NextToken = aws.String(params.NextToken)
+	}
+
+	output, err := s.client.ListGroups(ctx, input)
+	if err != nil {
+		return nil, mapAdminError(ctx, err, "Unable to list groups")
+	}
+
+	return &models.ListGroupsResponse{
+		Groups:    groupList(output.Groups),
+		NextToken: aws.ToString(output.NextToken),
+	}, nil
+}
+
+func (s *CognitoStore) CreateGroup(ctx context.Context, params *models.CreateGroupParams) (*models.Group, error) {
+	input := &cognitoidentityprovider.CreateGroupInput{
+		UserPoolId: aws.String(s.userPoolId),
+		GroupName:  aws.String(params.Name),
+		Precedence: params.Precedence,
+	}
+	if params.Description != "" {
+		input.Description = aws.String(params.Description)
+	}
+
+	output, err := s.client.CreateGroup(ctx, input)
+	if err != nil {
+		var groupExistsErr *types.GroupExistsException
+		if errors.As(err, &groupExistsErr) {
+			return nil, appError.NewGroupExistsError()
+		}
+		return nil, mapAdminError(ctx, err, "Unable to create group")
+	}
+
+	if output.Group == nil {
+		return nil, appError.NewServiceUnavailableError("Invalid create group result")
+	}
+	group := groupFromType(*output.Group)
+	return &group, nil
+}
+
+func (s *CognitoStore) DeleteGroup(ctx context.Context, name string) error {
+	_, err := s.client.DeleteGroup(ctx, &cognitoidentityprovider.DeleteGroupInput{
+		UserPoolId: aws.String(s.userPoolId),
+		GroupName:  aws.String(name),
+	})
+	if err != nil {
+		return mapGroupError(ctx, err, "Unable to delete group")
+	}
+	return nil
+}
+
+func (s *CognitoStore) AdminAddUserToGroup(ctx context.Context, username, group string) error {
+	_, err := s.client.AdminAddUserToGroup(ctx, &cognitoidentityprovider.AdminAddUserToGroupInput{
+		UserPoolId: aws.String(s.userPoolId),
+		Username:   aws.String(username),
+		GroupName:  aws.String(group),
+	})
+	if err != nil {
+		return mapGroupError(ctx, err, "Unable to add user to group")
+	}
+	return nil
+}
+
+func (s *CognitoStore) AdminRemoveUserFromGroup(ctx context.Context, username, group string) error {
+	_, err := s.client.AdminRemoveUserFromGroup(ctx, &cognitoidentityprovider.AdminRemoveUserFromGroupInput{
+		UserPoolId: aws.String(s.userPoolId),
+		Username:   aws.String(username),
+		GroupName:  aws.String(group),
+	})
+	if err != nil {
+		return mapGroupError(ctx, err, "Unable to remove user from group")
+	}
+	return nil
+}
+
+func (s *CognitoStore) AdminListGroupsForUser(ctx context.Context, username string, params *models.ListGroupsParams) (*models.ListGroupsResponse, error) {
+	input := &cognitoidentityprovider.AdminListGroupsForUserInput{
+		UserPoolId: aws.String(s.userPoolId),
+		Username:   aws.String(username),
+	}
+	if params.Limit > 0 {
+		input.Limit = aws.Int32(int32(params.Limit))
+	}
+	if params.NextToken != "" {
+		input.NextToken = aws.String(params.NextToken)
+	}
+
+	output, err := s.client.AdminListGroupsForUser(ctx, input)
+	if err != nil {
+		return nil, mapAdminError(ctx, err, "Unable to list groups for user")
+	}
+
+	return &models.ListGroupsResponse{
+		Groups:    groupList(output.Groups),
+		NextToken: aws.ToString(output.NextToken),
+	}, nil
+}
+
+// mapAdminError maps the exceptions shared by the Admin* operations. Anything
+// unexpected, including the service's own IAM permissions being refused, is
+// logged and reported as the service being unavailable.
+func mapAdminError(ctx context.Context, err error, unavailable string) error {
+	var notFoundErr *types.UserNotFoundException
+	var invalidParamErr *types.InvalidParameterException
+	var notAuthErr *types.NotAuthorizedException
+	var limitExceededErr *types.LimitExceededException
+	var tooManyRequestsErr *types.TooManyRequestsException
+
+	switch {
+	case errors.As(err, &notFoundErr):
+		return appError.NewUserNotFoundError()
+	case errors.As(err, &invalidParamErr):
+		return appError.NewInvalidInputError("").WithCause(err)
+	case errors.As(err, &notAuthErr):
+		return appError.NewInvalidInputError("Operation not allowed for this user")
+	case errors.As(err, &limitExceededErr), errors.As(err, &tooManyRequestsErr):
+		return appError.NewTooManyRequestsError("Please try again later")
+	default:
+		slog.ErrorContext(ctx, "Admin operation failed", "err", err)
+		return appError.NewServiceUnavailableError(unavailable)
+	}
+}
+
+// mapGroupError is mapAdminError for operations that name a group, where
+// ResourceNotFoundException means the group doesn't exist.
+func mapGroupError(ctx context.Context, err error, unavailable string) error {
+	var resourceNotFoundErr *types.ResourceNotFoundException
+	if errors.As(err, &resourceNotFoundErr) {
+		return appError.NewGroupNotFoundError()
+	}
+	return mapAdminError(ctx, err, unavailable)
+}
+
+// cognitoFilter builds a ListUsers filter expression, quoting the value so it
+// can't change the expression.
+func cognitoFilter(params *models.ListUsersParams) string {
+	operator := "="
+	if params.FilterType == models.FilterTypePrefix {
+		operator = "^="
+	}
+	value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(params.FilterValue)
+	return fmt.Sprintf(`%s %s "%s"`, params.FilterAttribute, operator, value)
+}
+
+func attributeMap(attributes []types.AttributeType) map[string]string {
+	attributesMap := make(map[string]string, len(attributes))
+	for _, attribute := range attributes {
+		attributesMap[aws.ToString(attribute.Name)] = aws.ToString(attribute.Value)
+	}
+	return attributesMap
+}
+
+func groupList(groups []types.GroupType) []models.Group {
+	list := make([]models.Group, 0, len(groups))
+	for _, group := range groups {
+		list = append(list, groupFromType(group))
+	}
+	return list
+}
+
+func groupFromType(group types.GroupType) models.Group {
+	return models.Group{
+		Name:        aws.ToString(group.GroupName),
+		Description: aws.ToString(group.Description),
+		Precedence:  group.Precedence,
+		CreatedAt:   aws.ToTime(group.CreationDate),
+		UpdatedAt:   aws.ToTime(group.LastModifiedDate),
+	}
+}
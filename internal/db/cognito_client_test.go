@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+
+	cip "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+)
+
+// fakeCognitoClient answers every call with err, and with an empty output
+// when err is nil. It records the operations it was called with.
+type fakeCognitoClient struct {
+	err   error
+	calls []string
+}
+
+func (f *fakeCognitoClient) call(op string) error {
+	f.calls = append(f.calls, op)
+	return f.err
+}
+
+func (f *fakeCognitoClient) SignUp(_ context.Context, _ *cip.SignUpInput, _ ...func(*cip.Options)) (*cip.SignUpOutput, error) {
+	return &cip.SignUpOutput{}, f.call("SignUp")
+}
+
+func (f *fakeCognitoClient) ConfirmSignUp(_ context.Context, _ *cip.ConfirmSignUpInput, _ ...func(*cip.Options)) (*cip.ConfirmSignUpOutput, error) {
+	return &cip.ConfirmSignUpOutput{}, f.call("ConfirmSignUp")
+}
+
+func (f *fakeCognitoClient) ResendConfirmationCode(_ context.Context, _ *cip.ResendConfirmationCodeInput, _ ...func(*cip.Options)) (*cip.ResendConfirmationCodeOutput, error) {
+	return &cip.ResendConfirmationCodeOutput{}, f.call("ResendConfirmationCode")
+}
+
+func (f *fakeCognitoClient) InitiateAuth(_ context.Context, _ *cip.InitiateAuthInput, _ ...func(*cip.Options)) (*cip.InitiateAuthOutput, error) {
+	return &cip.InitiateAuthOutput{}, f.call("InitiateAuth")
+}
+
+func (f *fakeCognitoClient) RespondToAuthChallenge(_ context.Context, _ *cip.RespondToAuthChallengeInput, _ ...func(*cip.Options)) (*cip.RespondToAuthChallengeOutput, error) {
+	return &cip.RespondToAuthChallengeOutput{}, f.call("RespondToAuthChallenge")
+}
+
+func (f *fakeCognitoClient) RevokeToken(_ context.Context, _ *cip.RevokeTokenInput, _ ...func(*cip.Options)) (*cip.RevokeTokenOutput, error) {
+	return &cip.RevokeTokenOutput{}, f.call("RevokeToken")
+}
+
+func (f *fakeCognitoClient) GlobalSignOut(_ context.Context, _ *cip.GlobalSignOutInput, _ ...func(*cip.Options)) (*cip.GlobalSignOutOutput, error) {
+	return &cip.GlobalSignOutOutput{}, f.call("GlobalSignOut")
+}
+
+func (f *fakeCognitoClient) ForgotPassword(_ context.Context, _ *cip.ForgotPasswordInput, _ ...func(*cip.Options)) (*cip.ForgotPasswordOutput, error) {
+	return &cip.ForgotPasswordOutput{}, f.call("ForgotPassword")
+}
+
+func (f *fakeCognitoClient) ConfirmForgotPassword(_ context.Context, _ *cip.ConfirmForgotPasswordInput, _ ...func(*cip.Options)) (*cip.ConfirmForgotPasswordOutput, error) {
+	return &cip.ConfirmForgotPasswordOutput{}, f.call("ConfirmForgotPassword")
+}
+
+func (f *fakeCognitoClient) GetUser(_ context.Context, _ *cip.GetUserInput, _ ...func(*cip.Options)) (*cip.GetUserOutput, error) {
+	return &cip.GetUserOutput{}, f.call("GetUser")
+}
+
+func (f *fakeCognitoClient) UpdateUserAttributes(_ context.Context, _ *cip.UpdateUserAttributesInput, _ ...func(*cip.Options)) (*cip.UpdateUserAttributesOutput, error) {
+	return &cip.UpdateUserAttributesOutput{}, f.call("UpdateUserAttributes")
+}
+
+func (f *fakeCognitoClient) GetUserAttributeVerificationCode(_ context.Context, _ *cip.GetUserAttributeVerificationCodeInput, _ ...func(*cip.Options)) (*cip.GetUserAttributeVerificationCodeOutput, error) {
+	return &cip.GetUserAttributeVerificationCodeOutput{}, f.call("GetUserAttributeVerificationCode")
+}
+
+func (f *fakeCognitoClient) VerifyUserAttribute(_ context.Context, _ *cip.VerifyUserAttributeInput, _ ...func(*cip.Options)) (*cip.VerifyUserAttributeOutput, error) {
+	return &cip.VerifyUserAttributeOutput{}, f.call("VerifyUserAttribute")
+}
+
+func (f *fakeCognitoClient) ChangePassword(_ context.Context, _ *cip.ChangePasswordInput, _ ...func(*cip.Options)) (*cip.ChangePasswordOutput, error) {
+	return &cip.ChangePasswordOutput{}, f.call("ChangePassword")
+}
+
+func (f *fakeCognitoClient) DeleteUser(_ context.Context, _ *cip.DeleteUserInput, _ ...func(*cip.Options)) (*cip.DeleteUserOutput, error) {
+	return &cip.DeleteUserOutput{}, f.call("DeleteUser")
+}
+
+func (f *fakeCognitoClient) AssociateSoftwareToken(_ context.Context, _ *cip.AssociateSoftwareTokenInput, _ ...func(*cip.Options)) (*cip.AssociateSoftwareTokenOutput, error) {
+	return &cip.AssociateSoftwareTokenOutput{}, f.call("AssociateSoftwareToken")
+}
+
+func (f *fakeCognitoClient) VerifySoftwareToken(_ context.Context, _ *cip.VerifySoftwareTokenInput, _ ...func(*cip.Options)) (*cip.VerifySoftwareTokenOutput, error) {
+	return &cip.VerifySoftwareTokenOutput{}, f.call("VerifySoftwareToken")
+}
+
+func (f *fakeCognitoClient) SetUserMFAPreference(_ context.Context, _ *cip.SetUserMFAPreferenceInput, _ ...func(*cip.Options)) (*cip.SetUserMFAPreferenceOutput, error) {
+	return &cip.SetUserMFAPreferenceOutput{}, f.call("SetUserMFAPreference")
+}
+
+func (f *fakeCognitoClient) ListUsers(_ context.Context, _ *cip.ListUsersInput, _ ...func(*cip.Options)) (*cip.ListUsersOutput, error) {
+	return &cip.ListUsersOutput{}, f.call("ListUsers")
+}
+
+func (f *fakeCognitoClient) AdminGetUser(_ context.Context, _ *cip.AdminGetUserInput, _ ...func(*cip.Options)) (*cip.AdminGetUserOutput, error) {
+	return &cip.AdminGetUserOutput{}, f.call("AdminGetUser")
+}
+
+func (f *fakeCognitoClient) AdminDisableUser(_ context.Context, _ *cip.AdminDisableUserInput, _ ...func(*cip.Options)) (*cip.AdminDisableUserOutput, error) {
+	return &cip.AdminDisableUserOutput{}, f.call("AdminDisableUser")
+}
+
+func (f *fakeCognitoClient) AdminEnableUser(_ context.Context, _ *cip.AdminEnableUserInput, _ ...func(*cip.Options)) (*cip.AdminEnableUserOutput, error) {
+	return &cip.AdminEnableUserOutput{}, f.call("AdminEnableUser")
+}
+
+func (f *fakeCognitoClient) AdminDeleteUser(_ context.Context, _ *cip.AdminDeleteUserInput, _ ...func(*cip.Options)) (*cip.AdminDeleteUserOutput, error) {
+	return &cip.AdminDeleteUserOutput{}, f.call("AdminDeleteUser")
+}
+
+func (f *fakeCognitoClient) AdminConfirmSignUp(_ context.Context, _ *cip.AdminConfirmSignUpInput, _ ...func(*cip.Options)) (*cip.AdminConfirmSignUpOutput, error) {
+	return &cip.AdminConfirmSignUpOutput{}, f.call("AdminConfirmSignUp")
+}
+
+func (f *fakeCognitoClient) AdminResetUserPassword(_ context.Context, _ *cip.AdminResetUserPasswordInput, _ ...func(*cip.Options)) (*cip.AdminResetUserPasswordOutput, error) {
+	return &cip.AdminResetUserPasswordOutput{}, f.call("AdminResetUserPassword")
+}
+
+func (f *fakeCognitoClient) AdminUserGlobalSignOut(_ context.Context, _ *cip.AdminUserGlobalSignOutInput, _ ...func(*cip.Options)) (*cip.AdminUserGlobalSignOutOutput, error) {
+	return &cip.AdminUserGlobalSignOutOutput{}, f.call("AdminUserGlobalSignOut")
+}
+
+func (f *fakeCognitoClient) ListGroups(_ context.Context, _ *cip.ListGroupsInput, _ ...func(*cip.Options)) (*cip.ListGroupsOutput, error) {
+	return &cip.ListGroupsOutput{}, f.call("ListGroups")
+}
+
+func (f *fakeCognitoClient) CreateGroup(_ context.Context, _ *cip.CreateGroupInput, _ ...func(*cip.Options)) (*cip.CreateGroupOutput, error) {
+	return &cip.CreateGroupOutput{}, f.call("CreateGroup")
+}
+
+func (f *fakeCognitoClient) DeleteGroup(_ context.Context, _ *cip.DeleteGroupInput, _ ...func(*cip.Options)) (*cip.DeleteGroupOutput, error) {
+	return &cip.DeleteGroupOutput{}, f.call("DeleteGroup")
+}
+
+func (f *fakeCognitoClient) AdminAddUserToGroup(_ context.Context, _ *cip.AdminAddUserToGroupInput, _ ...func(*cip.Options)) (*cip.AdminAddUserToGroupOutput, error) {
+	return &cip.AdminAddUserToGroupOutput{}, f.call("AdminAddUserToGroup")
+}
+
+func (f *fakeCognitoClient) AdminRemoveUserFromGroup(_ context.Context, _ *cip.AdminRemoveUserFromGroupInput, _ ...func(*cip.Options)) (*cip.AdminRemoveUserFromGroupOutput, error) {
+	return &cip.AdminRemoveUserFromGroupOutput{}, f.call("AdminRemoveUserFromGroup")
+}
+
+func (f *fakeCognitoClient) AdminListGroupsForUser(_ context.Context, _ *cip.AdminListGroupsForUserInput, _ ...func(*cip.Options)) (*cip.AdminListGroupsForUserOutput, error) {
+	return &cip.AdminListGroupsForUserOutput{}, f.call("AdminListGroupsForUser")
+}
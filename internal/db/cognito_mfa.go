@@ -0,0 +1,117 @@
+package db
+
+import (
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+func (s *CognitoStore) AssociateSoftwareToken(ctx context.Context, token, accountName string) (*models.MFAEnrollmentResponse, error) {
+	output, err := s.client.AssociateSoftwareToken(ctx, &cognitoidentityprovider.AssociateSoftwareTokenInput{
+		AccessToken: aws.String(token),
+	})
+
+	if err != nil {
+		return nil, mapMFAError(ctx, err, "Unable to start MFA enrollment")
+	}
+
+	secret := aws.ToString(output.SecretCode)
+	if secret == "" {
+		return nil, appError.NewServiceUnavailableError("Invalid MFA enrollment result")
+	}
+
+	return &models.MFAEnrollmentResponse{
+		SecretCode: secret,
+		URI:        totpURI(s.mfaIssuer, accountName, secret),
+	}, nil
+}
+
+func (s *CognitoStore) VerifySoftwareToken(ctx context.Context, token string, params *models.MFAVerifyParams) error {
+	input := &cognitoidentityprovider.VerifySoftwareTokenInput{
+		AccessToken: aws.String(token),
+		UserCode:    aws.String(params.Code),
+	}
+	if params.DeviceName != "" {
+		input.FriendlyDeviceName = aws.String(params.DeviceName)
+	}
+
+	output, err := s.client.VerifySoftwareToken(ctx, input)
+	if err != nil {
+		return mapMFAError(ctx, err, "Unable to verify MFA code")
+	}
+
+	if output.Status != types.VerifySoftwareTokenResponseTypeSuccess {
+		return appError.NewInvalidCodeError("")
+	}
+
+	return nil
+}
+
+func (s *CognitoStore) SetMFAPreference(ctx context.Context, token string, params *models.MFAPreferenceParams) error {
+	input := &cognitoidentityprovider.SetUserMFAPreferenceInput{
+		AccessToken: aws.String(token),
+	}
+	if params.TOTP != nil {
+		input.SoftwareTokenMfaSettings = &types.SoftwareTokenMfaSettingsType{
+			Enabled:      params.TOTP.Enabled,
+			PreferredMfa: params.TOTP.Preferred,
+		}
+	}
+	if params.SMS != nil {
+		input.SMSMfaSettings = &types.SMSMfaSettingsType{
+			Enabled:      params.SMS.Enabled,
+			PreferredMfa: params.SMS.Preferred,
+		}
+	}
+
+	_, err := s.client.SetUserMFAPreference(ctx, input)
+	if err != nil {
+		return mapMFAError(ctx, err, "Unable to update MFA preference")
+	}
+
+	return nil
+}
+
+// mapMFAError maps the exceptions shared by the MFA management operations.
+func mapMFAError(ctx context.Context, err error, unavailable string) error {
+	var notAuthErr *types.NotAuthorizedException
+	var forbiddenErr *types.ForbiddenException
+	var codeMismatchErr *types.CodeMismatchException
+	var enableErr *types.EnableSoftwareTokenMFAException
+	var notFoundErr *types.SoftwareTokenMFANotFoundException
+	var invalidParamErr *types.InvalidParameterException
+	var limitExceededErr *types.LimitExceededException
+	var tooManyRequestsErr *types.TooManyRequestsException
+
+	switch {
+	case errors.As(err, &notAuthErr), errors.As(err, &forbiddenErr):
+		return appError.NewInvalidCredentialsError("")
+	case errors.As(err, &codeMismatchErr), errors.As(err, &enableErr):
+		return appError.NewInvalidCodeError("")
+	case errors.As(err, &notFoundErr):
+		return appError.NewInvalidInputError("TOTP is not set up for this account")
+	case errors.As(err, &invalidParamErr):
+		return appError.NewInvalidInputError("").WithCause(err)
+	case errors.As(err, &limitExceededErr), errors.As(err, &tooManyRequestsErr):
+		return appError.NewTooManyRequestsError("Please try again later")
+	default:
+		slog.ErrorContext(ctx, "MFA operation failed", "err", err)
+		return appError.NewServiceUnavailableError(unavailable)
+	}
+}
+
+// totpURI builds the otpauth:// URI authenticator apps read from a QR code.
+func totpURI(issuer, accountName, secret string) string {
+	label := url.PathEscape(issuer + ":" + accountName)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
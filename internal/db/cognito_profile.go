@@ -0,0 +1,176 @@
+package db
+
+import (
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+func (s *CognitoStore) UpdateUserAttributes(ctx context.Context, token string, attributes map[string]string) ([]models.CodeDelivery, error) {
+	output, err := s.client.UpdateUserAttributes(ctx, &cognitoidentityprovider.UpdateUserAttributesInput{
+		AccessToken:    aws.String(token),
+		UserAttributes: attributeTypes(attributes),
+	})
+	if err != nil {
+		return nil, mapProfileError(ctx, err, "Unable to update user attributes")
+	}
+
+	deliveries := make([]models.CodeDelivery, 0, len(output.CodeDeliveryDetailsList))
+	for _, details := range output.CodeDeliveryDetailsList {
+		deliveries = append(deliveries, codeDelivery(details))
+	}
+	return deliveries, nil
+}
+
+func (s *CognitoStore) SendAttributeVerificationCode(ctx context.Context, token, attribute string) (*models.CodeDelivery, error) {
+	output, err := s.client.GetUserAttributeVerificationCode(ctx, &cognitoidentityprovider.GetUserAttributeVerificationCodeInput{
+		AccessToken:   aws.String(token),
+		AttributeName: aws.String(attribute),
+	})
+	if err != nil {
+		return nil, mapProfileError(ctx, err, "Unable to send verification code")
+	}
+
+	if output.CodeDeliveryDetails == nil {
+		return nil, appError.NewServiceUnavailableError("Invalid verification code result")
+	}
+	delivery := codeDelivery(*output.CodeDeliveryDetails)
+	return &delivery, nil
+}
+
+func (s *CognitoStore) VerifyUserAttribute(ctx context.Context, token string, params *models.UserConfirmAttributeParams) error {
+	_, err := s.client.VerifyUserAttribute(ctx, &cognitoidentityprovider.VerifyUserAttributeInput{
+		AccessToken:   aws.String(token),
+		AttributeName: aws.String(params.Attribute),
+		Code:          aws.String(params.Code),
+	})
+	if err != nil {
+		return mapProfileError(ctx, err, "Unable to verify attribute")
+	}
+
+	return nil
+}
+
+func (s *CognitoStore) ChangePassword(ctx context.Context, token string, params *models.UserChangePasswordParams) error {
+	_, err := s.client.ChangePassword(ctx, &cognitoidentityprovider.ChangePasswordInput{
+		AccessToken:      aws.String(token),
+		PreviousPassword: aws.String(params.CurrentPassword),
+		ProposedPassword: aws.String(params.NewPassword),
+	})
+	if err != nil {
+		var notAuthErr *types.NotAuthorizedException
+		var invalidPasswordErr *types.InvalidPasswordException
+		var limitExceededErr *types.LimitExceededException
+
+		switch {
+		case errors.As(err, &notAuthErr):
+			// The middleware only checks the token's signature and expiry, so
+			// a token revoked or signed out upstream gets here too, under the
+			// same exception as a wrong password. Only the message tells them
+			// apart, and only a wrong password may count as a failed login.
+			msg := strings.ToLower(notAuthErr.ErrorMessage())
+			switch {
+			case !strings.Contains(msg, "access token"):
+				return appError.NewIncorrectPasswordError()
+			case strings.Contains(msg, "expired"):
+				return appError.NewTokenExpiredError()
+			case strings.Contains(msg, "revoked"):
+				return appError.NewRevokedTokenError()
+			default:
+				return appError.NewInvalidTokenError("")
+			}
+		case errors.As(err, &invalidPasswordErr):
+			return appError.NewInvalidPasswordError("").WithCause(err)
+		case errors.As(err, &limitExceededErr):
+			return appError.NewTooManyRequestsError("Too many password change attempts, please try again later")
+		}
+		return mapProfileError(ctx, err, "Unable to change password")
+	}
+
+	return nil
+}
+
+func (s *CognitoStore) DeleteUser(ctx context.Context, token string) error {
+	_, err := s.client.DeleteUser(ctx, &cognitoidentityprovider.DeleteUserInput{
+		AccessToken: aws.String(token),
+	})
+	if err != nil {
+		return mapProfileError(ctx, err, "Unable to delete user")
+	}
+
+	return nil
+}
+
+// mapProfileError maps the exceptions shared by the operations a user runs on
+// their own attributes.
+func mapProfileError(ctx context.Context, err error, unavailable string) error {
+	var notAuthErr *types.NotAuthorizedException
+	var forbiddenErr *types.ForbiddenException
+	var aliasExistsErr *types.AliasExistsException
+	var codeMismatchErr *types.CodeMismatchException
+	var expiredCodeErr *types.ExpiredCodeException
+	var invalidParamErr *types.InvalidParameterException
+	var limitExceededErr *types.LimitExceededException
+	var tooManyRequestsErr *types.TooManyRequestsException
+
+	switch {
+	case errors.As(err, &notAuthErr), errors.As(err, &forbiddenErr):
+		return appError.NewInvalidCredentialsError("")
+	case errors.As(err, &aliasExistsErr):
+		return appError.NewAccountExistsError()
+	case errors.As(err, &codeMismatchErr):
+		return appError.NewInvalidCodeError("")
+	case errors.As(err, &expiredCodeErr):
+		return appError.NewExpiredCodeError()
+	case errors.As(err, &invalidParamErr):
+		return appError.NewInvalidInputError("").WithCause(err)
+	case errors.As(err, &limitExceededErr), errors.As(err, &tooManyRequestsErr):
+		return appError.NewTooManyRequestsError("Please try again later")
+	default:
+		slog.ErrorContext(ctx, "User attribute operation failed", "err", err)
+		return appError.NewServiceUnavailableError(unavailable)
+	}
+}
+
+// signUpAttributes returns the attributes a new account starts with.
+func signUpAttributes(user *models.User) map[string]string {
+	attributes := make(map[string]string, len(user.Attributes)+2)
+	for name, value := range user.Attributes {
+		attributes[name] = value
+	}
+	attributes["name"] = user.Name
+	attributes["email"] = user.Email
+	return attributes
+}
+
+// attributeTypes converts attributes to the list Cognito expects, in name
+// order so requests are stable.
+func attributeTypes(attributes map[string]string) []types.AttributeType {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]types.AttributeType, 0, len(names))
+	for _, name := range names {
+		list = append(list, types.AttributeType{Name: aws.String(name), Value: aws.String(attributes[name])})
+	}
+	return list
+}
+
+func codeDelivery(details types.CodeDeliveryDetailsType) models.CodeDelivery {
+	return models.CodeDelivery{
+		Attribute:   aws.ToString(details.AttributeName),
+		Medium:      string(details.DeliveryMedium),
+		Destination: aws.ToString(details.Destination),
+	}
+}
@@ -0,0 +1,513 @@
+package db
+
+import (
+	"app/internal/config"
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// upstreamMessage is the message of every Cognito exception below. It must
+// never reach a client.
+const upstreamMessage = "upstream detail that must not leak"
+
+// cognitoExceptions are the exceptions the store methods are tested against,
+// keyed by their Cognito name. Each is wrapped the way the SDK wraps them.
+var cognitoExceptions = map[string]error{
+	"AliasExistsException":            &types.AliasExistsException{Message: aws.String(upstreamMessage)},
+	"CodeDeliveryFailureException":    &types.CodeDeliveryFailureException{Message: aws.String(upstreamMessage)},
+	"CodeMismatchException":           &types.CodeMismatchException{Message: aws.String(upstreamMessage)},
+	"ExpiredCodeException":            &types.ExpiredCodeException{Message: aws.String(upstreamMessage)},
+	"ForbiddenException":              &types.ForbiddenException{Message: aws.String(upstreamMessage)},
+	"InternalErrorException":          &types.InternalErrorException{Message: aws.String(upstreamMessage)},
+	"InvalidLambdaResponseException":  &types.InvalidLambdaResponseException{Message: aws.String(upstreamMessage)},
+	"InvalidParameterException":       &types.InvalidParameterException{Message: aws.String(upstreamMessage)},
+	"InvalidPasswordException":        &types.InvalidPasswordException{Message: aws.String(upstreamMessage)},
+	"LimitExceededException":          &types.LimitExceededException{Message: aws.String(upstreamMessage)},
+	"NotAuthorizedException":          &types.NotAuthorizedException{Message: aws.String(upstreamMessage)},
+	"PasswordResetRequiredException":  &types.PasswordResetRequiredException{Message: aws.String(upstreamMessage)},
+	"ResourceNotFoundException":       &types.ResourceNotFoundException{Message: aws.String(upstreamMessage)},
+	"TooManyFailedAttemptsException":  &types.TooManyFailedAttemptsException{Message: aws.String(upstreamMessage)},
+	"TooManyRequestsException":        &types.TooManyRequestsException{Message: aws.String(upstreamMessage)},
+	"UnauthorizedException":           &types.UnauthorizedException{Message: aws.String(upstreamMessage)},
+	"UnsupportedTokenTypeException":   &types.UnsupportedTokenTypeException{Message: aws.String(upstreamMessage)},
+	"UserNotConfirmedException":       &types.UserNotConfirmedException{Message: aws.String(upstreamMessage)},
+	"UserNotFoundException":           &types.UserNotFoundException{Message: aws.String(upstreamMessage)},
+	"UsernameExistsException":         &types.UsernameExistsException{Message: aws.String(upstreamMessage)},
+	"not a Cognito exception":         errors.New(upstreamMessage),
+	"canceled before Cognito replied": context.DeadlineExceeded,
+}
+
+func TestCognitoStoreErrorMapping(t *testing.T) {
+	tests := []struct {
+		method string
+		call   func(ctx context.Context, s *CognitoStore) error
+		// want maps exception names to the sentinel the method returns for
+		// them, nil meaning success. Any other exception must be reported as
+		// ErrServiceUnavailable.
+		want map[string]error
+	}{
+		{
+			method: "SignUp",
+			call: func(ctx context.Context, s *CognitoStore) error {
+				return s.SignUp(ctx, &models.User{Name: "Jane", Email: "jane@example.com", Password: "Passw0rd!"})
+			},
+			want: map[string]error{
+				"UsernameExistsException":   appError.ErrAccountExists,
+				"InvalidPasswordException":  appError.ErrInvalidPassword,
+				"InvalidParameterException": appError.ErrInvalidInput,
+			},
+		},
+		{
+			method: "ConfirmAccount",
+			call: func(ctx context.Context, s *CognitoStore) error {
+				return s.ConfirmAccount(ctx, &models.UserConfirmationParams{Email: "jane@example.com", Code: "123456"})
+			},
+			want: map[string]error{
+				"CodeMismatchException": appError.ErrInvalidCode,
+				"ExpiredCodeException":  appError.ErrExpiredCode,
+				"UserNotFoundException": appError.ErrInvalidInput,
+			},
+		},
+		{
+			method: "ResendConfirmationCode",
+			call: func(ctx context.Context, s *CognitoStore) error {
+				return s.ResendConfirmationCode(ctx, &models.UserResendCodeParams{Email: "jane@example.com"})
+			},
+			want: map[string]error{
+				"UserNotFoundException":     nil,
+				"InvalidParameterException": appError.ErrInvalidInput,
+				"LimitExceededException":    appError.ErrTooManyRequests,
+			},
+		},
+		{
+			method: "Login",
+			call: func(ctx context.Context, s *CognitoStore) error {
+				_, err := s.Login(ctx, &models.UserLoginParams{Email: "jane@example.com", Password: "Passw0rd!"})
+				return err
+			},
+			want: map[string]error{
+				"PasswordResetRequiredException": appError.ErrPasswordReset,
+				"NotAuthorizedException":         appError.ErrInvalidCredentials,
+				"UserNotFoundException":          appError.ErrInvalidCredentials,
+				"UserNotConfirmedException":      appError.ErrInvalidInput,
+			},
+		},
+		{
+			method: "RespondToChallenge",
+			call: func(ctx context.Context, s *CognitoStore) error {
+				_, err := s.RespondToChallenge(ctx, &models.UserChallengeParams{
+					Username:      "jane@example.com",
+					ChallengeName: string(types.ChallengeNameTypeSoftwareTokenMfa),
+					Session:       "session",
+					Code:          "123456",
+				})
+				return err
+			},
+			want: map[string]error{
+				"CodeMismatchException":          appError.ErrInvalidCode,
+				"ExpiredCodeException":           appError.ErrExpiredCode,
+				"InvalidPasswordException":       appError.ErrInvalidPassword,
+				"InvalidParameterException":      appError.ErrInvalidInput,
+				"NotAuthorizedException":         appError.ErrInvalidCredentials,
+				"UserNotFoundException":          appError.ErrInvalidCredentials,
+				"TooManyFailedAttemptsException": appError.ErrTooManyRequests,
+				"LimitExceededException":         appError.ErrTooManyRequests,
+			},
+		},
+		{
+			method: "GetUser",
+			call: func(ctx context.Context, s *CognitoStore) error {
+				_, err := s.GetUser(ctx, "access-token")
+				return err
+			},
+			want: map[string]error{
+				"ForbiddenException":        appError.ErrInvalidInput,
+				"InvalidParameterException": appError.ErrInvalidInput,
+				"NotAuthorizedException":    appError.ErrInvalidInput,
+			},
+		},
+		{
+			method: "Refresh",
+			call: func(ctx context.Context, s *CognitoStore) error {
+				_, err := s.Refresh(ctx, &models.UserRefreshParams{Username: "jane", RefreshToken: "refresh-token"})
+				return err
+			},
+			want: map[string]error{
+				"NotAuthorizedException": appError.ErrInvalidRefreshToken,
+				"UserNotFoundException":  appError.ErrInvalidRefreshToken,
+			},
+		},
+		{
+			method: "RevokeToken",
+			call: func(ctx context.Context, s *CognitoStore) error {
+				return s.RevokeToken(ctx, "refresh-token")
+			},
+			want: map[string]error{
+				"UnauthorizedException":         appError.ErrInvalidRefreshToken,
+				"UnsupportedTokenTypeException": appError.ErrInvalidRefreshToken,
+				"InvalidParameterException":     appError.ErrInvalidRefreshToken,
+				"TooManyRequestsException":      appError.ErrTooManyRequests,
+			},
+		},
+		{
+			method: "GlobalSignOut",
+			call: func(ctx context.Context, s *CognitoStore) error {
+				return s.GlobalSignOut(ctx, "access-token")
+			},
+			want: map[string]error{
+				"NotAuthorizedException":   appError.ErrInvalidCredentials,
+				"TooManyRequestsException": appError.ErrTooManyRequests,
+			},
+		},
+		{
+			method: "ForgotPassword",
+			call: func(ctx context.Context, s *CognitoStore) error {
+				return s.ForgotPassword(ctx, &models.UserForgotPasswordParams{Email: "jane@example.com"})
+			},
+			want: map[string]error{
+				"UserNotFoundException":     nil,
+				"InvalidParameterException": appError.ErrInvalidInput,
+				"LimitExceededException":    appError.ErrTooManyRequests,
+			},
+		},
+		{
+			method: "ConfirmForgotPassword",
+			call: func(ctx context.Context, s *CognitoStore) error {
+				return s.ConfirmForgotPassword(ctx, &models.UserResetPasswordParams{Email: "jane@example.com", Code: "123456", Password: "Passw0rd!"})
+			},
+			want: map[string]error{
+				"CodeMismatchException":          appError.ErrInvalidCode,
+				"ExpiredCodeException":           appError.ErrExpiredCode,
+				"InvalidPasswordException":       appError.ErrInvalidPassword,
+				"UserNotFoundException":          appError.ErrInvalidCode,
+				"LimitExceededException":         appError.ErrTooManyRequests,
+				"TooManyFailedAttemptsException": appError.ErrTooManyRequests,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for name, exception := range cognitoExceptions {
+			t.Run(tt.method+"/"+name, func(t *testing.T) {
+				client := &fakeCognitoClient{err: fmt.Errorf("operation error: %w", exception)}
+				store := &CognitoStore{client: client, clientId: "client", clientSecret: "secret"}
+
+				want, mapped := tt.want[name]
+				if !mapped {
+					want = appError.ErrServiceUnavailable
+				}
+
+				err := tt.call(context.Background(), store)
+				if len(client.calls) != 1 {
+					t.Fatalf("Cognito calls = %v, want exactly one", client.calls)
+				}
+				if want == nil {
+					if err != nil {
+						t.Fatalf("err = %v, want nil", err)
+					}
+					return
+				}
+
+				var authErr *appError.AuthError
+				if !errors.As(err, &authErr) {
+					t.Fatalf("err = %v, want an AuthError", err)
+				}
+				if !errors.Is(err, want) {
+					t.Errorf("err = %v, want %v", err, want)
+				}
+				if strings.Contains(authErr.Error(), upstreamMessage) {
+					t.Errorf("client message %q leaks the upstream error", authErr.Error())
+				}
+			})
+		}
+	}
+}
+
+func TestCognitoStoreRefreshTokenMessages(t *testing.T) {
+	tests := []struct {
+		message string
+		want    error
+	}{
+		{"Refresh Token has expired", appError.ErrExpiredRefreshToken},
+		{"Refresh Token has been revoked", appError.ErrRevokedRefreshToken},
+		{"Invalid Refresh Token", appError.ErrInvalidRefreshToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			client := &fakeCognitoClient{err: &types.NotAuthorizedException{Message: aws.String(tt.message)}}
+			store := &CognitoStore{client: client, clientId: "client", clientSecret: "secret"}
+
+			_, err := store.Refresh(context.Background(), &models.UserRefreshParams{Username: "jane", RefreshToken: "refresh-token"})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCognitoStoreChangePasswordMessages(t *testing.T) {
+	tests := []struct {
+		message string
+		want    error
+	}{
+		{"Incorrect username or password.", appError.ErrIncorrectPassword},
+		{"Access Token has expired", appError.ErrTokenExpired},
+		{"Access Token has been revoked", appError.ErrRevokedToken},
+		{"Invalid Access Token", appError.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			client := &fakeCognitoClient{err: &types.NotAuthorizedException{Message: aws.String(tt.message)}}
+			store := &CognitoStore{client: client, clientId: "client", clientSecret: "secret"}
+
+			err := store.ChangePassword(context.Background(), "access-token", &models.UserChangePasswordParams{CurrentPassword: "Passw0rd!", NewPassword: "N3wPassw0rd!"})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateSecretHash(t *testing.T) {
+	tests := []struct {
+		clientSecret string
+		clientId     string
+		username     string
+		want         string
+	}{
+		{"secret", "client", "user@example.com", "4zqhOFl0JivfkWh1VINyOJyrDTdsinJktzPUT+t0plg="},
+		{"9p4q2k1j8h7g6f5d4s3a2l1k0j9h8g7f6d5s4a3", "4v3q7k1o2m9n8b7c6x5z4l3k2j", "Alice@Example.com", "t9YeyVrDNAYOoqJKfseOE5Ilv1xCj2vVi7MQ3msKU6Q="},
+		{"", "client", "user", "zK9QzY5TmHfD+rRuK+aBhky4ZBKofCmDjPIo9e40yYE="},
+	}
+
+	for _, tt := range tests {
+		store := &CognitoStore{clientId: tt.clientId, clientSecret: tt.clientSecret}
+		if got := store.generateSecretHash(tt.username); got != tt.want {
+			t.Errorf("generateSecretHash(%q) with client %q = %q, want %q", tt.username, tt.clientId, got, tt.want)
+		}
+	}
+}
+
+const (
+	testIssuer   = "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_test"
+	testClientId = "client"
+	testKeyID    = "test-key"
+)
+
+// newJWKSServer serves the public half of key as a JWK set under kid.
+func newJWKSServer(t *testing.T, key *rsa.PrivateKey, kid string) *httptest.Server {
+	t.Helper()
+
+	jwkKey, err := jwk.FromRaw(key.Public())
+	if err != nil {
+		t.Fatalf("failed to build JWK: %v", err)
+	}
+	jwkKey.Set(jwk.KeyIDKey, kid)
+	jwkKey.Set(jwk.AlgorithmKey, jwa.RS256)
+	set := jwk.NewSet()
+	set.AddKey(jwkKey)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestCognitoStoreValidateToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	srv := newJWKSServer(t, key, testKeyID)
+
+	store, err := NewCognitoStoreWithClient(&config.Config{
+		AwsCognitoClientId:     testClientId,
+		AwsTokenURL:            srv.URL,
+		AwsJWTIssuerURL:        testIssuer,
+		JwksRefreshInterval:    time.Hour,
+		JwksMinRefetchInterval: time.Hour,
+		TokenUse:               config.TokenUseAccess,
+	}, &fakeCognitoClient{})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	now := time.Now()
+	accessClaims := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"sub":       "user-sub",
+			"iss":       testIssuer,
+			"client_id": testClientId,
+			"token_use": "access",
+			"username":  "jane",
+			"jti":       "token-id",
+			"iat":       now.Unix(),
+			"exp":       now.Add(time.Hour).Unix(),
+		}
+		if change != nil {
+			change(claims)
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{
+			name:  "valid access token",
+			token: signToken(t, key, testKeyID, accessClaims(nil)),
+		},
+		{
+			name:  "expired",
+			token: signToken(t, key, testKeyID, accessClaims(func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() })),
+			want:  appError.ErrTokenExpired,
+		},
+		{
+			name:  "missing expiry",
+			token: signToken(t, key, testKeyID, accessClaims(func(c jwt.MapClaims) { delete(c, "exp") })),
+			want:  appError.ErrInvalidToken,
+		},
+		{
+			name:  "issued in the future",
+			token: signToken(t, key, testKeyID, accessClaims(func(c jwt.MapClaims) { c["iat"] = now.Add(time.Hour).Unix() })),
+			want:  appError.ErrInvalidToken,
+		},
+		{
+			name:  "other issuer",
+			token: signToken(t, key, testKeyID, accessClaims(func(c jwt.MapClaims) { c["iss"] = "https://example.com" })),
+			want:  appError.ErrInvalidTokenIssuer,
+		},
+		{
+			name:  "id token",
+			token: signToken(t, key, testKeyID, accessClaims(func(c jwt.MapClaims) { c["token_use"] = "id"; c["aud"] = testClientId })),
+			want:  appError.ErrInvalidTokenUse,
+		},
+		{
+			name:  "other client",
+			token: signToken(t, key, testKeyID, accessClaims(func(c jwt.MapClaims) { c["client_id"] = "other" })),
+			want:  appError.ErrInvalidTokenAudience,
+		},
+		{
+			name:  "signed by another key",
+			token: signToken(t, otherKey, testKeyID, accessClaims(nil)),
+			want:  appError.ErrInvalidToken,
+		},
+		{
+			name:  "unknown key ID",
+			token: signToken(t, key, "unknown-key", accessClaims(nil)),
+			want:  appError.ErrInvalidToken,
+		},
+		{
+			name: "HMAC signed",
+			token: func() string {
+				signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims(nil)).SignedString([]byte("secret"))
+				return signed
+			}(),
+			want: appError.ErrInvalidToken,
+		},
+		{
+			name:  "malformed",
+			token: "not.a.token",
+			want:  appError.ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := store.ValidateToken(tt.token)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				claims, err := store.GetClaims(token)
+				if err != nil {
+					t.Fatalf("failed to get claims: %v", err)
+				}
+				if claims.Sub != "user-sub" || claims.Username != "jane" {
+					t.Errorf("claims = %+v, want sub user-sub and username jane", claims)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCognitoClientOptions(t *testing.T) {
+	t.Run("overrides", func(t *testing.T) {
+		o := cognitoidentityprovider.Options{Region: "eu-west-1", RetryMaxAttempts: 3}
+		cognitoClientOptions(config.CognitoClient{
+			EndpointURL:      "http://localhost:9229",
+			Region:           "us-east-1",
+			RetryMode:        aws.RetryModeAdaptive,
+			RetryMaxAttempts: 5,
+			HTTPTimeout:      2 * time.Second,
+		})(&o)
+
+		if aws.ToString(o.BaseEndpoint) != "http://localhost:9229" {
+			t.Errorf("BaseEndpoint = %q, want http://localhost:9229", aws.ToString(o.BaseEndpoint))
+		}
+		if o.Region != "us-east-1" {
+			t.Errorf("Region = %q, want us-east-1", o.Region)
+		}
+		if o.RetryMode != aws.RetryModeAdaptive || o.RetryMaxAttempts != 5 {
+			t.Errorf("retries = %s/%d, want adaptive/5", o.RetryMode, o.RetryMaxAttempts)
+		}
+		client, ok := o.HTTPClient.(*awshttp.BuildableClient)
+		if !ok || client.GetTimeout() != 2*time.Second {
+			t.Errorf("HTTPClient = %#v, want a client with a 2s timeout", o.HTTPClient)
+		}
+	})
+
+	t.Run("zero keeps the defaults", func(t *testing.T) {
+		o := cognitoidentityprovider.Options{Region: "eu-west-1", RetryMaxAttempts: 3}
+		cognitoClientOptions(config.CognitoClient{})(&o)
+
+		if o.BaseEndpoint != nil || o.Region != "eu-west-1" || o.RetryMode != "" || o.RetryMaxAttempts != 3 || o.HTTPClient != nil {
+			t.Errorf("options = %+v, want them unchanged", o)
+		}
+	})
+}
@@ -1,17 +1,72 @@
 package db
 
 import (
+	"app/internal/config"
 	"app/internal/models"
 	"context"
+	"fmt"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthStore interface {
 	ValidateToken(tokenString string) (*jwt.Token, error)
-	GetClaims(token *jwt.Token) (map[string]interface{}, error)
+	GetClaims(token *jwt.Token) (*models.Claims, error)
 	SignUp(ctx context.Context, user *models.User) error
 	ConfirmAccount(ctx context.Context, user *models.UserConfirmationParams) error
+	ResendConfirmationCode(ctx context.Context, params *models.UserResendCodeParams) error
 	Login(ctx context.Context, user *models.UserLoginParams) (*models.AuthLoginResponse, error)
+	RespondToChallenge(ctx context.Context, params *models.UserChallengeParams) (*models.AuthLoginResponse, error)
 	GetUser(ctx context.Context, token string) (*models.UserInfoResponse, error)
+	UpdateUserAttributes(ctx context.Context, token string, attributes map[string]string) ([]models.CodeDelivery, error)
+	SendAttributeVerificationCode(ctx context.Context, token, attribute string) (*models.CodeDelivery, error)
+	VerifyUserAttribute(ctx context.Context, token string, params *models.UserConfirmAttributeParams) error
+	ChangePassword(ctx context.Context, token string, params *models.UserChangePasswordParams) error
+	DeleteUser(ctx context.Context, token string) error
+	Refresh(ctx context.Context, params *models.UserRefreshParams) (*models.AuthLoginResponse, error)
+	RevokeToken(ctx context.Context, refreshToken string) error
+	GlobalSignOut(ctx context.Context, token string) error
+	AssociateSoftwareToken(ctx context.Context, token, accountName string) (*models.MFAEnrollmentResponse, error)
+	VerifySoftwareToken(ctx context.Context, token string, params *models.MFAVerifyParams) error
+	SetMFAPreference(ctx context.Context, token string, params *models.MFAPreferenceParams) error
+
+	ListUsers(ctx context.Context, params *models.ListUsersParams) (*models.ListUsersResponse, error)
+	AdminGetUser(ctx context.Context, username string) (*models.AdminUser, error)
+	AdminDisableUser(ctx context.Context, username string) error
+	AdminEnableUser(ctx context.Context, username string) error
+	AdminDeleteUser(ctx context.Context, username string) error
+	AdminConfirmSignUp(ctx context.Context, username string) error
+	AdminResetUserPassword(ctx context.Context, username string) error
+	AdminUserGlobalSignOut(ctx context.Context, username string) error
+
+	ListGroups(ctx context.Context, params *models.ListGroupsParams) (*models.ListGroupsResponse, error)
+	CreateGroup(ctx context.Context, params *models.CreateGroupParams) (*models.Group, error)
+	DeleteGroup(ctx context.Context, name string) error
+	AdminAddUserToGroup(ctx context.Context, username, group string) error
+	AdminRemoveUserFromGroup(ctx context.Context, username, group string) error
+	AdminListGroupsForUser(ctx context.Context, username string, params *models.ListGroupsParams) (*models.ListGroupsResponse, error)
+	ForgotPassword(ctx context.Context, params *models.UserForgotPasswordParams) error
+	ConfirmForgotPassword(ctx context.Context, params *models.UserResetPasswordParams) error
+}
+
+// NewAuthStore builds the AuthStore selected by cfg.AuthBackend: "cognito"
+// talks to the configured user pool, "memory" keeps everything in process for
+// offline development and tests.
+func NewAuthStore(cfg *config.Config) (AuthStore, error) {
+	switch cfg.AuthBackend {
+	case "", "cognito":
+		store, err := NewCognitoStore(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "memory":
+		store, err := NewMemoryStore(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown auth backend %q", cfg.AuthBackend)
+	}
 }
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+const (
+	jwksStartupTimeout = 5 * time.Second
+	jwksFetchTimeout   = 10 * time.Second
+)
+
+// jwksProvider keeps the signing keys of the user pool fresh. The set is
+// refreshed in the background on a fixed interval and refetched on demand when
+// a token names a key we don't know yet, which is how a key rotation shows up.
+// On-demand refetches are rate limited so tokens with made-up key IDs can't
+// turn into a stream of requests to Cognito.
+type jwksProvider struct {
+	url                string
+	cache              *jwk.Cache
+	minRefetchInterval time.Duration
+
+	mu          sync.Mutex
+	lastRefetch time.Time
+}
+
+func newJWKSProvider(url string, refreshInterval, minRefetchInterval time.Duration) (*jwksProvider, error) {
+	cache := jwk.NewCache(context.Background())
+	if err := cache.Register(url, jwk.WithRefreshInterval(refreshInterval)); err != nil {
+		return nil, fmt.Errorf("failed to register JWK set: %w", err)
+	}
+
+	// A slow or failing first fetch shouldn't keep the server from starting,
+	// the first token to be validated will try again.
+	ctx, cancel := context.WithTimeout(context.Background(), jwksStartupTimeout)
+	defer cancel()
+	if _, err := cache.Refresh(ctx, url); err != nil {
+		slog.Warn("JWK set not available yet, will retry on first use", "url", url, "err", err)
+	}
+
+	return &jwksProvider{
+		url:                url,
+		cache:              cache,
+		minRefetchInterval: minRefetchInterval,
+	}, nil
+}
+
+func (p *jwksProvider) lookupKeyID(kid string) (jwk.Key, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+
+	// Get fails when no fetch has succeeded yet, which is treated like an
+	// unknown key so it is retried under the same rate limit.
+	keySet, err := p.cache.Get(ctx, p.url)
+	if err == nil {
+		if key, found := keySet.LookupKeyID(kid); found {
+			return key, nil
+		}
+	}
+
+	if !p.allowRefetch() {
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch JWK set: %w", err)
+		}
+		return nil, errors.New("key not found in JWKS")
+	}
+
+	slog.Info("refetching JWK set", "kid", kid)
+	keySet, err = p.cache.Refresh(ctx, p.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWK set: %w", err)
+	}
+
+	key, found := keySet.LookupKeyID(kid)
+	if !found {
+		return nil, errors.New("key not found in JWKS")
+	}
+	return key, nil
+}
+
+func (p *jwksProvider) allowRefetch() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(p.lastRefetch) < p.minRefetchInterval {
+		return false
+	}
+	p.lastRefetch = now
+	return true
+}
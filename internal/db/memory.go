@@ -0,0 +1,711 @@
+package db
+
+import (
+	"app/internal/config"
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	memoryDefaultIssuer   = "http://localhost/memory"
+	memoryDefaultClientId = "memory"
+
+	memoryAccessTokenTTL  = time.Hour
+	memoryRefreshTokenTTL = 30 * 24 * time.Hour
+	memorySignUpCodeTTL   = 24 * time.Hour
+	memoryResetCodeTTL    = time.Hour
+	memorySessionTTL      = 3 * time.Minute
+
+	// memoryMaxChallengeAttempts is how many wrong codes a challenge session
+	// takes before it is dropped and the user has to log in again.
+	memoryMaxChallengeAttempts = 3
+
+	memoryMinPasswordLength = 8
+	memoryPasswordIter      = 100_000
+)
+
+var errMemoryNotAuthorized = errors.New("invalid access token")
+
+type memoryCode struct {
+	value     string
+	expiresAt time.Time
+}
+
+type memoryUser struct {
+	username      string
+	attributes    map[string]string
+	password      *memoryPassword
+	confirmed     bool
+	enabled       bool
+	resetRequired bool
+	signUpCode    *memoryCode
+	resetCode     *memoryCode
+	verifyCodes   map[string]*memoryCode
+	totpSecret    string
+	totpPending   string
+	totpEnabled   bool
+	groups        map[string]bool
+	signedOutAt   time.Time
+	createdAt     time.Time
+	updatedAt     time.Time
+}
+
+// memoryPassword is a salted PBKDF2 hash of a password. Hashing is slow on
+// purpose, so it is done without holding s.mu; a new password replaces the
+// pointer, which lets callers tell whether it changed while they hashed.
+type memoryPassword struct {
+	salt []byte
+	hash []byte
+}
+
+// memoryDummyPassword is checked when a login names no user, so unknown
+// emails take as long to reject as wrong passwords.
+var memoryDummyPassword = &memoryPassword{
+	salt: make([]byte, 16),
+	hash: make([]byte, sha256.Size),
+}
+
+type memorySession struct {
+	username  string
+	originJti string
+	expiresAt time.Time
+	revoked   bool
+}
+
+type memoryChallenge struct {
+	name      types.ChallengeNameType
+	username  string
+	attempts  int
+	expiresAt time.Time
+}
+
+// MemoryStore is an AuthStore that keeps everything in process memory and
+// signs its own RS256 tokens, so the service can run and be tested without a
+// Cognito user pool. It reports failures with the same AuthErrors as
+// CognitoStore. Codes that Cognito would email are written to the log.
+type MemoryStore struct {
+	mu         sync.Mutex
+	clientId   string
+	issuer     string
+	mfaIssuer  string
+	keyId      string
+	signingKey *rsa.PrivateKey
+	validator  *tokenValidator
+	users      map[string]*memoryUser
+	emails     map[string]string
+	sessions   map[string]*memorySession
+	challenges map[string]*memoryChallenge
+	groups     map[string]*memoryGroup
+}
+
+func NewMemoryStore(cfg *config.Config) (*MemoryStore, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	keyId, err := randomHex(8)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key ID: %w", err)
+	}
+
+	issuer := cfg.AwsJWTIssuerURL
+	if issuer == "" {
+		issuer = memoryDefaultIssuer
+	}
+	clientId := cfg.AwsCognitoClientId
+	if clientId == "" {
+		clientId = memoryDefaultClientId
+	}
+
+	return &MemoryStore{
+		clientId:   clientId,
+		issuer:     issuer,
+		mfaIssuer:  cfg.MfaTotpIssuer,
+		keyId:      keyId,
+		signingKey: key,
+		validator:  newTokenValidator(cfg, issuer, clientId),
+		users:      make(map[string]*memoryUser),
+		emails:     make(map[string]string),
+		sessions:   make(map[string]*memorySession),
+		challenges: make(map[string]*memoryChallenge),
+		groups:     make(map[string]*memoryGroup),
+	}, nil
+}
+
+func (s *MemoryStore) ValidateToken(tokenString string) (*jwt.Token, error) {
+	return s.validator.validate(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if kid, _ := token.Header["kid"].(string); kid != s.keyId {
+			return nil, errors.New("key not found in JWKS")
+		}
+		return &s.signingKey.PublicKey, nil
+	})
+}
+
+func (s *MemoryStore) GetClaims(token *jwt.Token) (*models.Claims, error) {
+	return claimsFromToken(token)
+}
+
+func (s *MemoryStore) GetUser(ctx context.Context, token string) (*models.UserInfoResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.accessUser(token)
+	if err != nil {
+		return nil, appError.NewInvalidInputError("Invalid access token")
+	}
+
+	attributes := make(map[string]string, len(user.attributes))
+	for name, value := range user.attributes {
+		attributes[name] = value
+	}
+
+	return &models.UserInfoResponse{
+		Attributes: attributes,
+		Username:   user.username,
+	}, nil
+}
+
+func (s *MemoryStore) SignUp(ctx context.Context, user *models.User) error {
+	if user.Email == "" {
+		return appError.NewInvalidInputError("Email is required")
+	}
+	if err := checkMemoryPassword(user.Password); err != nil {
+		return err
+	}
+	password, err := newMemoryPassword(user.Password)
+	if err != nil {
+		return appError.NewServiceUnavailableError("Unable to process registration")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	email := strings.ToLower(user.Email)
+	if _, ok := s.emails[email]; ok {
+		return appError.NewAccountExistsError()
+	}
+
+	username, err := newUUID()
+	if err != nil {
+		return appError.NewServiceUnavailableError("Unable to process registration")
+	}
+
+	code, err := newMemoryCode(memorySignUpCodeTTL)
+	if err != nil {
+		return appError.NewServiceUnavailableError("Unable to process registration")
+	}
+
+	attributes := signUpAttributes(user)
+	attributes["sub"] = username
+	attributes["email_verified"] = "false"
+
+	stored := &memoryUser{
+		username:   username,
+		attributes: attributes,
+		enabled:    true,
+		groups:     make(map[string]bool),
+		password:   password,
+		signUpCode: code,
+		createdAt:  time.Now(),
+		updatedAt:  time.Now(),
+	}
+
+	s.users[username] = stored
+	s.emails[email] = username
+
+	slog.InfoContext(ctx, "confirmation code issued", "email", user.Email, "code", code.value)
+	return nil
+}
+
+func (s *MemoryStore) ConfirmAccount(ctx context.Context, user *models.UserConfirmationParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.lookup(user.Email)
+	if stored == nil {
+		return appError.NewInvalidInputError("User not found")
+	}
+	if stored.confirmed {
+		return appError.NewInvalidInputError("Account already confirmed")
+	}
+
+	if err := checkMemoryCode(stored.signUpCode, user.Code); err != nil {
+		return err
+	}
+
+	stored.confirmed = true
+	stored.signUpCode = nil
+	stored.attributes["email_verified"] = "true"
+	stored.updatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) ResendConfirmationCode(ctx context.Context, params *models.UserResendCodeParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.lookup(params.Email)
+	if stored == nil {
+		return nil
+	}
+	if stored.confirmed {
+		return appError.NewInvalidInputError("Account already confirmed")
+	}
+
+	code, err := newMemoryCode(memorySignUpCodeTTL)
+	if err != nil {
+		return appError.NewServiceUnavailableError("Unable to resend confirmation code")
+	}
+	stored.signUpCode = code
+
+	slog.InfoContext(ctx, "confirmation code issued", "email", params.Email, "code", code.value)
+	return nil
+}
+
+func (s *MemoryStore) Login(ctx context.Context, user *models.UserLoginParams) (*models.AuthLoginResponse, error) {
+	s.mu.Lock()
+	stored := s.lookup(user.Email)
+	password := memoryDummyPassword
+	if stored != nil {
+		password = stored.password
+	}
+	s.mu.Unlock()
+
+	ok := password.check(user.Password)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The user may have been deleted or changed their password while the
+	// password was hashed.
+	if stored == nil || !ok || s.lookup(user.Email) != stored || stored.password != password || !stored.enabled {
+		return nil, appError.NewInvalidCredentialsError("")
+	}
+
+	switch {
+	case stored.resetRequired:
+		return nil, appError.NewPasswordResetError()
+	case !stored.confirmed:
+		return nil, appError.NewInvalidInputError("Account not confirmed")
+	}
+
+	if stored.totpEnabled {
+		session, err := randomToken()
+		if err != nil {
+			return nil, appError.NewServiceUnavailableError("Authentication service unavailable")
+		}
+		s.challenges[session] = &memoryChallenge{
+			name:      types.ChallengeNameTypeSoftwareTokenMfa,
+			username:  stored.username,
+			expiresAt: time.Now().Add(memorySessionTTL),
+		}
+		return models.NewAuthChallengeResponse(
+			string(types.ChallengeNameTypeSoftwareTokenMfa),
+			session,
+			map[string]string{"USER_ID_FOR_SRP": stored.username},
+		), nil
+	}
+
+	return s.issueTokens(stored)
+}
+
+func (s *MemoryStore) RespondToChallenge(ctx context.Context, params *models.UserChallengeParams) (*models.AuthLoginResponse, error) {
+	if types.ChallengeNameType(params.ChallengeName) != types.ChallengeNameTypeSoftwareTokenMfa {
+		return nil, appError.NewInvalidInputError("Unsupported challenge")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[params.Session]
+	if !ok || time.Now().After(challenge.expiresAt) || string(challenge.name) != params.ChallengeName {
+		return nil, appError.NewInvalidCredentialsError("")
+	}
+
+	stored := s.lookup(params.Username)
+	if stored == nil || stored.username != challenge.username {
+		return nil, appError.NewInvalidCredentialsError("")
+	}
+
+	if !verifyTOTP(stored.totpSecret, params.Code, time.Now()) {
+		if challenge.attempts++; challenge.attempts >= memoryMaxChallengeAttempts {
+			delete(s.challenges, params.Session)
+		}
+		return nil, appError.NewInvalidCodeError("")
+	}
+
+	delete(s.challenges, params.Session)
+	return s.issueTokens(stored)
+}
+
+func (s *MemoryStore) Refresh(ctx context.Context, params *models.UserRefreshParams) (*models.AuthLoginResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[params.RefreshToken]
+	switch {
+	case !ok:
+		return nil, appError.NewInvalidRefreshTokenError()
+	case session.revoked:
+		return nil, appError.NewRevokedRefreshTokenError()
+	case time.Now().After(session.expiresAt):
+		return nil, appError.NewExpiredRefreshTokenError()
+	}
+
+	// Cognito would reject a secret hash computed for another user.
+	stored := s.lookup(params.Username)
+	if stored == nil || stored.username != session.username || !stored.enabled {
+		return nil, appError.NewInvalidRefreshTokenError()
+	}
+
+	accessToken, err := s.signAccessToken(stored, session.originJti)
+	if err != nil {
+		return nil, appError.NewServiceUnavailableError("Authentication service unavailable")
+	}
+
+	return models.NewAuthLoginResponse(accessToken, params.RefreshToken, int(memoryAccessTokenTTL.Seconds())), nil
+}
+
+func (s *MemoryStore) RevokeToken(ctx context.Context, refreshToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[refreshToken]
+	if !ok {
+		return appError.NewInvalidRefreshTokenError()
+	}
+	session.revoked = true
+	return nil
+}
+
+func (s *MemoryStore) GlobalSignOut(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.accessUser(token)
+	if err != nil {
+		return appError.NewInvalidCredentialsError("Session is no longer valid")
+	}
+
+	s.signOut(user)
+	return nil
+}
+
+func (s *MemoryStore) ForgotPassword(ctx context.Context, params *models.UserForgotPasswordParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.lookup(params.Email)
+	if stored == nil {
+		return nil
+	}
+	if !stored.confirmed {
+		return appError.NewInvalidInputError("Account has no verified email")
+	}
+
+	code, err := newMemoryCode(memoryResetCodeTTL)
+	if err != nil {
+		return appError.NewServiceUnavailableError("Unable to start password reset")
+	}
+	stored.resetCode = code
+
+	slog.InfoContext(ctx, "password reset code issued", "email", params.Email, "code", code.value)
+	return nil
+}
+
+func (s *MemoryStore) ConfirmForgotPassword(ctx context.Context, params *models.UserResetPasswordParams) error {
+	s.mu.Lock()
+	stored := s.lookup(params.Email)
+	if stored == nil {
+		s.mu.Unlock()
+		return appError.NewInvalidCodeError("")
+	}
+	code := stored.resetCode
+	s.mu.Unlock()
+
+	if err := checkMemoryCode(code, params.Code); err != nil {
+		return err
+	}
+	if err := checkMemoryPassword(params.Password); err != nil {
+		return err
+	}
+	password, err := newMemoryPassword(params.Password)
+	if err != nil {
+		return appError.NewServiceUnavailableError("Unable to reset password")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The code may have been used or replaced while the password was hashed.
+	if s.lookup(params.Email) != stored || stored.resetCode != code {
+		return appError.NewInvalidCodeError("")
+	}
+	stored.password = password
+	stored.resetCode = nil
+	stored.resetRequired = false
+	stored.updatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) AssociateSoftwareToken(ctx context.Context, token, accountName string) (*models.MFAEnrollmentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.accessUser(token)
+	if err != nil {
+		return nil, appError.NewInvalidCredentialsError("")
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, appError.NewServiceUnavailableError("Unable to start MFA enrollment")
+	}
+	user.totpPending = secret
+
+	return &models.MFAEnrollmentResponse{
+		SecretCode: secret,
+		URI:        totpURI(s.mfaIssuer, accountName, secret),
+	}, nil
+}
+
+func (s *MemoryStore) VerifySoftwareToken(ctx context.Context, token string, params *models.MFAVerifyParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.accessUser(token)
+	if err != nil {
+		return appError.NewInvalidCredentialsError("")
+	}
+
+	if user.totpPending == "" {
+		return appError.NewInvalidInputError("TOTP is not set up for this account")
+	}
+	if !verifyTOTP(user.totpPending, params.Code, time.Now()) {
+		return appError.NewInvalidCodeError("")
+	}
+
+	user.totpSecret = user.totpPending
+	user.totpPending = ""
+	return nil
+}
+
+func (s *MemoryStore) SetMFAPreference(ctx context.Context, token string, params *models.MFAPreferenceParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.accessUser(token)
+	if err != nil {
+		return appError.NewInvalidCredentialsError("")
+	}
+
+	if params.SMS != nil && params.SMS.Enabled {
+		return appError.NewInvalidInputError("SMS MFA is not available")
+	}
+	if params.TOTP != nil {
+		if params.TOTP.Enabled && user.totpSecret == "" {
+			return appError.NewInvalidInputError("TOTP is not set up for this account")
+		}
+		user.totpEnabled = params.TOTP.Enabled
+	}
+	return nil
+}
+
+// lookup finds a user by email or by Cognito username. Callers hold s.mu.
+func (s *MemoryStore) lookup(username string) *memoryUser {
+	if name, ok := s.emails[strings.ToLower(username)]; ok {
+		username = name
+	}
+	return s.users[username]
+}
+
+// accessUser resolves the user behind an access token, rejecting tokens that
+// were signed out the way Cognito would. Callers hold s.mu.
+func (s *MemoryStore) accessUser(tokenString string) (*memoryUser, error) {
+	token, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, errMemoryNotAuthorized
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if use, _ := claims["token_use"].(string); use != "access" {
+		return nil, errMemoryNotAuthorized
+	}
+
+	username, _ := claims["username"].(string)
+	user, ok := s.users[username]
+	if !ok || !user.enabled {
+		return nil, errMemoryNotAuthorized
+	}
+
+	issuedAt, err := claims.GetIssuedAt()
+	// iat only has second precision, so tokens issued in the second of the
+	// sign out are rejected too.
+	if err != nil || issuedAt == nil || !issuedAt.Time.After(user.signedOutAt.Truncate(time.Second)) {
+		return nil, errMemoryNotAuthorized
+	}
+
+	originJti, _ := claims["origin_jti"].(string)
+	for _, session := range s.sessions {
+		if session.originJti == originJti && session.revoked {
+			return nil, errMemoryNotAuthorized
+		}
+	}
+
+	return user, nil
+}
+
+// issueTokens starts a new session for user. Callers hold s.mu.
+func (s *MemoryStore) issueTokens(user *memoryUser) (*models.AuthLoginResponse, error) {
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, appError.NewServiceUnavailableError("Authentication service unavailable")
+	}
+	originJti, err := newUUID()
+	if err != nil {
+		return nil, appError.NewServiceUnavailableError("Authentication service unavailable")
+	}
+
+	accessToken, err := s.signAccessToken(user, originJti)
+	if err != nil {
+		return nil, appError.NewServiceUnavailableError("Authentication service unavailable")
+	}
+
+	s.sessions[refreshToken] = &memorySession{
+		username:  user.username,
+		originJti: originJti,
+		expiresAt: time.Now().Add(memoryRefreshTokenTTL),
+	}
+
+	return models.NewAuthLoginResponse(accessToken, refreshToken, int(memoryAccessTokenTTL.Seconds())), nil
+}
+
+// signAccessToken mints an access token carrying the same claims Cognito puts
+// in its own.
+func (s *MemoryStore) signAccessToken(user *memoryUser, originJti string) (string, error) {
+	jti, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+	eventId, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":        user.username,
+		"iss":        s.issuer,
+		"client_id":  s.clientId,
+		"origin_jti": originJti,
+		"event_id":   eventId,
+		"token_use":  "access",
+		"scope":      "aws.cognito.signin.user.admin",
+		"auth_time":  now.Unix(),
+		"iat":        now.Unix(),
+		"exp":        now.Add(memoryAccessTokenTTL).Unix(),
+		"jti":        jti,
+		"username":   user.username,
+	}
+	if len(user.groups) > 0 {
+		claims["cognito:groups"] = user.groupNames()
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.keyId
+
+	return token.SignedString(s.signingKey)
+}
+
+func newMemoryPassword(password string) (*memoryPassword, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	hash, err := pbkdf2.Key(sha256.New, password, salt, memoryPasswordIter, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryPassword{salt: salt, hash: hash}, nil
+}
+
+func (p *memoryPassword) check(password string) bool {
+	hash, err := pbkdf2.Key(sha256.New, password, p.salt, memoryPasswordIter, sha256.Size)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(hash, p.hash)
+}
+
+func checkMemoryPassword(password string) error {
+	if len(password) < memoryMinPasswordLength {
+		return appError.NewInvalidPasswordError("Password not long enough")
+	}
+	return nil
+}
+
+func checkMemoryCode(code *memoryCode, value string) error {
+	if code == nil || !hmac.Equal([]byte(code.value), []byte(value)) {
+		return appError.NewInvalidCodeError("")
+	}
+	if time.Now().After(code.expiresAt) {
+		return appError.NewExpiredCodeError()
+	}
+	return nil
+}
+
+func newMemoryCode(ttl time.Duration) (*memoryCode, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return nil, err
+	}
+	return &memoryCode{
+		value:     fmt.Sprintf("%06d", n.Int64()),
+		expiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
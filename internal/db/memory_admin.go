@@ -0,0 +1,220 @@
+package db
+
+import (
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+const memoryDefaultListLimit = 60
+
+func (s *MemoryStore) ListUsers(ctx context.Context, params *models.ListUsersParams) (*models.ListUsersResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := ""
+	if params.PaginationToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(params.PaginationToken)
+		if err != nil {
+			return nil, appError.NewInvalidInputError("Invalid pagination token")
+		}
+		start = string(decoded)
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = memoryDefaultListLimit
+	}
+
+	usernames := make([]string, 0, len(s.users))
+	for username, user := range s.users {
+		if username >= start && user.matches(params) {
+			usernames = append(usernames, username)
+		}
+	}
+	sort.Strings(usernames)
+
+	res := &models.ListUsersResponse{Users: []models.AdminUser{}}
+	for i, username := range usernames {
+		if i == limit {
+			res.PaginationToken = base64.RawURLEncoding.EncodeToString([]byte(username))
+			break
+		}
+		res.Users = append(res.Users, s.users[username].adminView())
+	}
+
+	return res, nil
+}
+
+func (s *MemoryStore) AdminGetUser(ctx context.Context, username string) (*models.AdminUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := s.lookup(username)
+	if user == nil {
+		return nil, appError.NewUserNotFoundError()
+	}
+	view := user.adminView()
+	return &view, nil
+}
+
+func (s *MemoryStore) AdminDisableUser(ctx context.Context, username string) error {
+	return s.updateUser(username, func(user *memoryUser) error {
+		user.enabled = false
+		return nil
+	})
+}
+
+func (s *MemoryStore) AdminEnableUser(ctx context.Context, username string) error {
+	return s.updateUser(username, func(user *memoryUser) error {
+		user.enabled = true
+		return nil
+	})
+}
+
+func (s *MemoryStore) AdminDeleteUser(ctx context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := s.lookup(username)
+	if user == nil {
+		return appError.NewUserNotFoundError()
+	}
+
+	s.deleteUser(user)
+	return nil
+}
+
+func (s *MemoryStore) AdminConfirmSignUp(ctx context.Context, username string) error {
+	return s.updateUser(username, func(user *memoryUser) error {
+		if user.confirmed {
+			return appError.NewInvalidInputError("Account already confirmed")
+		}
+		user.confirmed = true
+		user.signUpCode = nil
+		return nil
+	})
+}
+
+func (s *MemoryStore) AdminResetUserPassword(ctx context.Context, username string) error {
+	return s.updateUser(username, func(user *memoryUser) error {
+		if !user.confirmed {
+			return appError.NewInvalidInputError("Account has no verified email")
+		}
+
+		code, err := newMemoryCode(memoryResetCodeTTL)
+		if err != nil {
+			return appError.NewServiceUnavailableError("Unable to reset password")
+		}
+		user.resetCode = code
+		user.resetRequired = true
+
+		slog.InfoContext(ctx, "password reset code issued", "email", user.attributes["email"], "code", code.value)
+		return nil
+	})
+}
+
+func (s *MemoryStore) AdminUserGlobalSignOut(ctx context.Context, username string) error {
+	return s.updateUser(username, func(user *memoryUser) error {
+		s.signOut(user)
+		return nil
+	})
+}
+
+// updateUser applies update to the user named by username under the lock.
+func (s *MemoryStore) updateUser(username string, update func(*memoryUser) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := s.lookup(username)
+	if user == nil {
+		return appError.NewUserNotFoundError()
+	}
+
+	if err := update(user); err != nil {
+		return err
+	}
+	user.updatedAt = time.Now()
+	return nil
+}
+
+// signOut revokes every session of user. Callers hold s.mu.
+func (s *MemoryStore) signOut(user *memoryUser) {
+	for _, session := range s.sessions {
+		if session.username == user.username {
+			session.revoked = true
+		}
+	}
+	user.signedOutAt = time.Now()
+}
+
+func (u *memoryUser) status() types.UserStatusType {
+	switch {
+	case u.resetRequired:
+		return types.UserStatusTypeResetRequired
+	case !u.confirmed:
+		return types.UserStatusTypeUnconfirmed
+	default:
+		return types.UserStatusTypeConfirmed
+	}
+}
+
+func (u *memoryUser) adminView() models.AdminUser {
+	attributes := make(map[string]string, len(u.attributes))
+	for name, value := range u.attributes {
+		attributes[name] = value
+	}
+	return models.AdminUser{
+		Username:   u.username,
+		Attributes: attributes,
+		Enabled:    u.enabled,
+		Status:     string(u.status()),
+		CreatedAt:  u.createdAt,
+		UpdatedAt:  u.updatedAt,
+	}
+}
+
+// matches applies the ListUsers filter the way Cognito reads it.
+func (u *memoryUser) matches(params *models.ListUsersParams) bool {
+	if params.FilterAttribute == "" {
+		return true
+	}
+
+	var value string
+	switch params.FilterAttribute {
+	case "username":
+		value = u.username
+	case "cognito:user_status":
+		value = string(u.status())
+	case "status":
+		value = "Disabled"
+		if u.enabled {
+			value = "Enabled"
+		}
+	default:
+		value = u.attributes[params.FilterAttribute]
+	}
+
+	if params.FilterType == models.FilterTypePrefix {
+		return strings.HasPrefix(value, params.FilterValue)
+	}
+	return value == params.FilterValue
+}
+
+// deleteUser removes user and their sessions. Callers hold s.mu.
+func (s *MemoryStore) deleteUser(user *memoryUser) {
+	delete(s.users, user.username)
+	delete(s.emails, strings.ToLower(user.attributes["email"]))
+	for token, session := range s.sessions {
+		if session.username == user.username {
+			delete(s.sessions, token)
+		}
+	}
+}
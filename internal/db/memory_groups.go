@@ -0,0 +1,151 @@
+package db
+
+import (
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"encoding/base64"
+	"sort"
+	"time"
+)
+
+type memoryGroup struct {
+	description string
+	precedence  *int32
+	createdAt   time.Time
+	updatedAt   time.Time
+}
+
+func (s *MemoryStore) ListGroups(ctx context.Context, params *models.ListGroupsParams) (*models.ListGroupsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.groups))
+	for name := range s.groups {
+		names = append(names, name)
+	}
+	return s.pageGroups(names, params)
+}
+
+func (s *MemoryStore) CreateGroup(ctx context.Context, params *models.CreateGroupParams) (*models.Group, error) {
+	if params.Name == "" {
+		return nil, appError.NewInvalidInputError("Group name is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[params.Name]; ok {
+		return nil, appError.NewGroupExistsError()
+	}
+
+	now := time.Now()
+	group := &memoryGroup{
+		description: params.Description,
+		precedence:  params.Precedence,
+		createdAt:   now,
+		updatedAt:   now,
+	}
+	s.groups[params.Name] = group
+
+	view := group.view(params.Name)
+	return &view, nil
+}
+
+func (s *MemoryStore) DeleteGroup(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[name]; !ok {
+		return appError.NewGroupNotFoundError()
+	}
+
+	delete(s.groups, name)
+	for _, user := range s.users {
+		delete(user.groups, name)
+	}
+	return nil
+}
+
+func (s *MemoryStore) AdminAddUserToGroup(ctx context.Context, username, group string) error {
+	return s.updateUser(username, func(user *memoryUser) error {
+		if _, ok := s.groups[group]; !ok {
+			return appError.NewGroupNotFoundError()
+		}
+		user.groups[group] = true
+		return nil
+	})
+}
+
+func (s *MemoryStore) AdminRemoveUserFromGroup(ctx context.Context, username, group string) error {
+	return s.updateUser(username, func(user *memoryUser) error {
+		if _, ok := s.groups[group]; !ok {
+			return appError.NewGroupNotFoundError()
+		}
+		delete(user.groups, group)
+		return nil
+	})
+}
+
+func (s *MemoryStore) AdminListGroupsForUser(ctx context.Context, username string, params *models.ListGroupsParams) (*models.ListGroupsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := s.lookup(username)
+	if user == nil {
+		return nil, appError.NewUserNotFoundError()
+	}
+	return s.pageGroups(user.groupNames(), params)
+}
+
+// pageGroups returns one page of the named groups in name order. Callers hold
+// s.mu.
+func (s *MemoryStore) pageGroups(names []string, params *models.ListGroupsParams) (*models.ListGroupsResponse, error) {
+	start := ""
+	if params.NextToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(params.NextToken)
+		if err != nil {
+			return nil, appError.NewInvalidInputError("Invalid pagination token")
+		}
+		start = string(decoded)
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = memoryDefaultListLimit
+	}
+
+	sort.Strings(names)
+	res := &models.ListGroupsResponse{Groups: []models.Group{}}
+	for _, name := range names {
+		if name < start {
+			continue
+		}
+		if len(res.Groups) == limit {
+			res.NextToken = base64.RawURLEncoding.EncodeToString([]byte(name))
+			break
+		}
+		res.Groups = append(res.Groups, s.groups[name].view(name))
+	}
+
+	return res, nil
+}
+
+func (g *memoryGroup) view(name string) models.Group {
+	return models.Group{
+		Name:        name,
+		Description: g.description,
+		Precedence:  g.precedence,
+		CreatedAt:   g.createdAt,
+		UpdatedAt:   g.updatedAt,
+	}
+}
+
+func (u *memoryUser) groupNames() []string {
+	names := make([]string, 0, len(u.groups))
+	for name := range u.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
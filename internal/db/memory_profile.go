@@ -0,0 +1,184 @@
+package db
+
+import (
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const memoryVerifyCodeTTL = 24 * time.Hour
+
+// memoryVerifiedAttributes maps the attributes that have to be verified after
+// a change to the medium their code is sent by.
+var memoryVerifiedAttributes = map[string]string{
+	"email":        "EMAIL",
+	"phone_number": "SMS",
+}
+
+func (s *MemoryStore) UpdateUserAttributes(ctx context.Context, token string, attributes map[string]string) ([]models.CodeDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.accessUser(token)
+	if err != nil {
+		return nil, appError.NewInvalidCredentialsError("")
+	}
+
+	if email, ok := attributes["email"]; ok {
+		if email == "" {
+			return nil, appError.NewInvalidInputError("Email is required")
+		}
+		if owner, ok := s.emails[strings.ToLower(email)]; ok && owner != user.username {
+			return nil, appError.NewAccountExistsError()
+		}
+	}
+
+	deliveries := []models.CodeDelivery{}
+	for name, value := range attributes {
+		if user.attributes[name] == value {
+			continue
+		}
+
+		if name == "email" {
+			delete(s.emails, strings.ToLower(user.attributes["email"]))
+			s.emails[strings.ToLower(value)] = user.username
+		}
+		user.attributes[name] = value
+
+		if _, ok := memoryVerifiedAttributes[name]; ok && value != "" {
+			user.attributes[name+"_verified"] = "false"
+			delivery, err := s.sendVerifyCode(ctx, user, name)
+			if err != nil {
+				return nil, appError.NewServiceUnavailableError("Unable to update user attributes")
+			}
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	user.updatedAt = time.Now()
+
+	return deliveries, nil
+}
+
+func (s *MemoryStore) SendAttributeVerificationCode(ctx context.Context, token, attribute string) (*models.CodeDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.accessUser(token)
+	if err != nil {
+		return nil, appError.NewInvalidCredentialsError("")
+	}
+
+	if _, ok := memoryVerifiedAttributes[attribute]; !ok || user.attributes[attribute] == "" {
+		return nil, appError.NewInvalidInputError("Attribute cannot be verified")
+	}
+
+	delivery, err := s.sendVerifyCode(ctx, user, attribute)
+	if err != nil {
+		return nil, appError.NewServiceUnavailableError("Unable to send verification code")
+	}
+	return delivery, nil
+}
+
+func (s *MemoryStore) VerifyUserAttribute(ctx context.Context, token string, params *models.UserConfirmAttributeParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.accessUser(token)
+	if err != nil {
+		return appError.NewInvalidCredentialsError("")
+	}
+
+	if err := checkMemoryCode(user.verifyCodes[params.Attribute], params.Code); err != nil {
+		return err
+	}
+
+	delete(user.verifyCodes, params.Attribute)
+	user.attributes[params.Attribute+"_verified"] = "true"
+	user.updatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) ChangePassword(ctx context.Context, token string, params *models.UserChangePasswordParams) error {
+	s.mu.Lock()
+	user, err := s.accessUser(token)
+	if err != nil {
+		s.mu.Unlock()
+		return appError.NewInvalidCredentialsError("")
+	}
+	current := user.password
+	s.mu.Unlock()
+
+	if !current.check(params.CurrentPassword) {
+		return appError.NewIncorrectPasswordError()
+	}
+	if err := checkMemoryPassword(params.NewPassword); err != nil {
+		return err
+	}
+	password, err := newMemoryPassword(params.NewPassword)
+	if err != nil {
+		return appError.NewServiceUnavailableError("Unable to change password")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The user may have been deleted, signed out or given a new password
+	// while the passwords were hashed.
+	if again, err := s.accessUser(token); err != nil || again != user {
+		return appError.NewInvalidCredentialsError("")
+	}
+	if user.password != current {
+		return appError.NewIncorrectPasswordError()
+	}
+	user.password = password
+	user.updatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) DeleteUser(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.accessUser(token)
+	if err != nil {
+		return appError.NewInvalidCredentialsError("")
+	}
+
+	s.deleteUser(user)
+	return nil
+}
+
+// sendVerifyCode issues a new verification code for attribute. Callers hold
+// s.mu.
+func (s *MemoryStore) sendVerifyCode(ctx context.Context, user *memoryUser, attribute string) (*models.CodeDelivery, error) {
+	code, err := newMemoryCode(memoryVerifyCodeTTL)
+	if err != nil {
+		return nil, err
+	}
+	if user.verifyCodes == nil {
+		user.verifyCodes = make(map[string]*memoryCode)
+	}
+	user.verifyCodes[attribute] = code
+
+	slog.InfoContext(ctx, "attribute verification code issued", "attribute", attribute, "destination", user.attributes[attribute], "code", code.value)
+	return &models.CodeDelivery{
+		Attribute:   attribute,
+		Medium:      memoryVerifiedAttributes[attribute],
+		Destination: maskDestination(user.attributes[attribute]),
+	}, nil
+}
+
+// maskDestination hides most of an email address or phone number the way
+// Cognito does in its code delivery details.
+func maskDestination(value string) string {
+	if at := strings.Index(value, "@"); at > 0 && at < len(value)-1 {
+		return value[:1] + "***@" + value[at+1:at+2] + "***"
+	}
+	if len(value) > 4 {
+		return "+*******" + value[len(value)-4:]
+	}
+	return "***"
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"app/internal/config"
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreChallengeAttempts(t *testing.T) {
+	const (
+		email    = "jane@example.com"
+		password = "Passw0rd!"
+	)
+
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	s, err := NewMemoryStore(&config.Config{TokenUse: config.TokenUseAccess})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	ctx := context.Background()
+	if err := s.SignUp(ctx, &models.User{Name: "Jane", Email: email, Password: password}); err != nil {
+		t.Fatalf("SignUp: %v", err)
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		t.Fatalf("failed to create TOTP secret: %v", err)
+	}
+	user := s.lookup(email)
+	user.confirmed = true
+	user.totpSecret = secret
+	user.totpEnabled = true
+
+	wrongCode := "000000"
+	for i := 0; verifyTOTP(secret, wrongCode, time.Now()); i++ {
+		wrongCode = fmt.Sprintf("%06d", i)
+	}
+
+	// respond logs in and sends wrong codes before the right one, returning
+	// the error of the last response.
+	respond := func(t *testing.T, wrong int) error {
+		t.Helper()
+		login, err := s.Login(ctx, &models.UserLoginParams{Email: email, Password: password})
+		if err != nil || login.Challenge == nil {
+			t.Fatalf("Login = %+v, %v, want a challenge", login, err)
+		}
+		params := &models.UserChallengeParams{
+			Username:      user.username,
+			ChallengeName: login.Challenge.Name,
+			Session:       login.Challenge.Session,
+			Code:          wrongCode,
+		}
+		for i := 0; i < wrong; i++ {
+			if _, err := s.RespondToChallenge(ctx, params); !errors.Is(err, appError.ErrInvalidCode) {
+				t.Fatalf("wrong code %d: err = %v, want %v", i+1, err, appError.ErrInvalidCode)
+			}
+		}
+		if params.Code, err = totpCode(secret, time.Now()); err != nil {
+			t.Fatalf("failed to generate code: %v", err)
+		}
+		_, err = s.RespondToChallenge(ctx, params)
+		return err
+	}
+
+	tests := []struct {
+		name  string
+		wrong int
+		want  error
+	}{
+		{name: "right code", wrong: 0, want: nil},
+		{name: "right code after wrong ones", wrong: memoryMaxChallengeAttempts - 1, want: nil},
+		{name: "session dropped after too many wrong codes", wrong: memoryMaxChallengeAttempts, want: appError.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := respond(t, tt.wrong); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreWeakPassword(t *testing.T) {
+	const email = "jane@example.com"
+
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	s, err := NewMemoryStore(&config.Config{TokenUse: config.TokenUseAccess})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	ctx := context.Background()
+	if err := s.SignUp(ctx, &models.User{Name: "Jane", Email: email, Password: "Passw0rd!"}); err != nil {
+		t.Fatalf("SignUp: %v", err)
+	}
+	s.lookup(email).confirmed = true
+	if err := s.ForgotPassword(ctx, &models.UserForgotPasswordParams{Email: email}); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	code := s.lookup(email).resetCode.value
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{
+			name: "SignUp",
+			call: func() error {
+				return s.SignUp(ctx, &models.User{Name: "John", Email: "john@example.com", Password: "short"})
+			},
+		},
+		{
+			name: "ConfirmForgotPassword",
+			call: func() error {
+				return s.ConfirmForgotPassword(ctx, &models.UserResetPasswordParams{Email: email, Code: code, Password: "short"})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authErr *appError.AuthError
+			if err := tt.call(); !errors.As(err, &authErr) {
+				t.Fatalf("err = %v, want an AuthError", err)
+			}
+			if code := authErr.Code(); code != "INVALID_PASSWORD" {
+				t.Errorf("code = %s, want INVALID_PASSWORD", code)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"app/internal/config"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitDecision is the outcome of taking a request from a bucket.
+type RateLimitDecision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed. Zero when
+	// Allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps token buckets for rate limiting. Each key has a bucket
+// of limit.Requests tokens that refills evenly over limit.Period, and every
+// request takes one token.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit config.RateLimit) (RateLimitDecision, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// MemoryRateLimitStore is a RateLimitStore kept in process memory, so each
+// instance of the service limits on its own. Buckets are dropped once they
+// have refilled.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit config.RateLimit) (RateLimitDecision, error) {
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return RateLimitDecision{Allowed: true}, nil
+	}
+
+	now := s.now()
+	capacity := float64(limit.Requests)
+	perToken := limit.Period / time.Duration(limit.Requests)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+	elapsed := now.Sub(b.updated)
+	b.tokens = math.Min(capacity, b.tokens+float64(elapsed)/float64(perToken))
+	b.updated = now
+
+	decision := RateLimitDecision{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = time.Duration((capacity - b.tokens) * float64(perToken))
+	b.full = now.Add(decision.Reset)
+
+	s.sweep(now)
+	return decision, nil
+}
+
+// sweep drops full buckets, at most once a minute so busy routes don't walk
+// every bucket on each request.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"app/internal/config"
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestRateLimitStore returns a store whose clock only moves when the
+// returned function advances it.
+func newTestRateLimitStore() (*MemoryRateLimitStore, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryRateLimitStore()
+	s.now = func() time.Time { return now }
+	s.lastSweep = now
+	return s, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	limit := config.RateLimit{Requests: 2, Period: time.Minute}
+
+	steps := []struct {
+		name          string
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantReset     time.Duration
+		wantRetry     time.Duration
+	}{
+		{name: "first request", wantAllowed: true, wantRemaining: 1, wantReset: 30 * time.Second},
+		{name: "last token", wantAllowed: true, wantRemaining: 0, wantReset: time.Minute},
+		{name: "empty bucket", wantAllowed: false, wantRemaining: 0, wantReset: time.Minute, wantRetry: 30 * time.Second},
+		{name: "half a token refilled", advance: 15 * time.Second, wantAllowed: false, wantRemaining: 0, wantReset: 45 * time.Second, wantRetry: 15 * time.Second},
+		{name: "one token refilled", advance: 15 * time.Second, wantAllowed: true, wantRemaining: 0, wantReset: time.Minute},
+		{name: "refill stops at capacity", advance: 10 * time.Minute, wantAllowed: true, wantRemaining: 1, wantReset: 30 * time.Second},
+	}
+
+	s, advance := newTestRateLimitStore()
+	for _, step := range steps {
+		advance(step.advance)
+		got, err := s.Take(context.Background(), "login:ip:192.0.2.1", limit)
+		if err != nil {
+			t.Fatalf("%s: err = %v", step.name, err)
+		}
+		want := RateLimitDecision{
+			Allowed:    step.wantAllowed,
+			Limit:      limit.Requests,
+			Remaining:  step.wantRemaining,
+			Reset:      step.wantReset,
+			RetryAfter: step.wantRetry,
+		}
+		if got != want {
+			t.Errorf("%s: Take = %+v, want %+v", step.name, got, want)
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreKeys(t *testing.T) {
+	limit := config.RateLimit{Requests: 1, Period: time.Minute}
+	s, _ := newTestRateLimitStore()
+	ctx := context.Background()
+
+	for _, key := range []string{"login:ip:192.0.2.1", "login:ip:192.0.2.2", "signup:ip:192.0.2.1"} {
+		if got, _ := s.Take(ctx, key, limit); !got.Allowed {
+			t.Errorf("Take(%s) = %+v, want a fresh bucket", key, got)
+		}
+	}
+	if got, _ := s.Take(ctx, "login:ip:192.0.2.1", limit); got.Allowed {
+		t.Errorf("Take again = %+v, want it denied", got)
+	}
+}
+
+func TestMemoryRateLimitStoreNoLimit(t *testing.T) {
+	s, _ := newTestRateLimitStore()
+	for _, limit := range []config.RateLimit{{}, {Requests: 5}, {Period: time.Minute}} {
+		got, err := s.Take(context.Background(), "key", limit)
+		if err != nil || !got.Allowed {
+			t.Errorf("Take with %+v = %+v, %v, want it allowed", limit, got, err)
+		}
+	}
+	if len(s.buckets) != 0 {
+		t.Errorf("buckets = %d, want none for unlimited takes", len(s.buckets))
+	}
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	s, advance := newTestRateLimitStore()
+	ctx := context.Background()
+
+	s.Take(ctx, "short", config.RateLimit{Requests: 1, Period: 30 * time.Second})
+	s.Take(ctx, "long", config.RateLimit{Requests: 1, Period: time.Hour})
+
+	advance(30 * time.Second)
+	s.Take(ctx, "other", config.RateLimit{Requests: 1, Period: time.Hour})
+	if len(s.buckets) != 3 {
+		t.Fatalf("buckets = %d, want 3 before a minute has passed", len(s.buckets))
+	}
+
+	advance(30 * time.Second)
+	s.Take(ctx, "other", config.RateLimit{Requests: 1, Period: time.Hour})
+	if _, ok := s.buckets["short"]; ok {
+		t.Errorf("full bucket was not swept")
+	}
+	for _, key := range []string{"long", "other"} {
+		if _, ok := s.buckets[key]; !ok {
+			t.Errorf("bucket %s was swept before it refilled", key)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"app/internal/models"
+	"sync"
+	"time"
+)
+
+// RevocationList tracks access tokens that must be rejected before they
+// expire. Cognito keeps accepting a revoked access token until its TTL runs
+// out when it is only checked locally, so the auth middleware consults this
+// list after signature validation.
+type RevocationList interface {
+	// RevokeToken rejects every token whose jti or origin_jti matches id
+	// until expiresAt.
+	RevokeToken(id string, expiresAt time.Time)
+	// RevokeSubject rejects every token of sub issued at or before
+	// issuedUntil until expiresAt. iat only has second precision, so a token
+	// issued in the same second as issuedUntil is rejected too.
+	RevokeSubject(sub string, issuedUntil, expiresAt time.Time)
+	IsRevoked(claims *models.Claims) bool
+}
+
+type revokedSubject struct {
+	issuedUntil time.Time
+	expiresAt   time.Time
+}
+
+// MemoryRevocationList is a RevocationList kept in process memory. Entries
+// are dropped once every token they could match has expired.
+type MemoryRevocationList struct {
+	mu       sync.RWMutex
+	tokens   map[string]time.Time
+	subjects map[string]revokedSubject
+}
+
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{
+		tokens:   make(map[string]time.Time),
+		subjects: make(map[string]revokedSubject),
+	}
+}
+
+func (l *MemoryRevocationList) RevokeToken(id string, expiresAt time.Time) {
+	if id == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if current, ok := l.tokens[id]; !ok || expiresAt.After(current) {
+		l.tokens[id] = expiresAt
+	}
+	l.sweep(time.Now())
+}
+
+func (l *MemoryRevocationList) RevokeSubject(sub string, issuedUntil, expiresAt time.Time) {
+	if sub == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.subjects[sub] = revokedSubject{issuedUntil: issuedUntil, expiresAt: expiresAt}
+	l.sweep(time.Now())
+}
+
+func (l *MemoryRevocationList) IsRevoked(claims *models.Claims) bool {
+	now := time.Now()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, id := range []string{claims.Jti, claims.OriginJti} {
+		if expiresAt, ok := l.tokens[id]; ok && now.Before(expiresAt) {
+			return true
+		}
+	}
+
+	if revoked, ok := l.subjects[claims.Sub]; ok && now.Before(revoked.expiresAt) {
+		if claims.IssuedAt.IsZero() || !claims.IssuedAt.After(revoked.issuedUntil) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *MemoryRevocationList) sweep(now time.Time) {
+	for id, expiresAt := range l.tokens {
+		if !now.Before(expiresAt) {
+			delete(l.tokens, id)
+		}
+	}
+	for sub, revoked := range l.subjects {
+		if !now.Before(revoked.expiresAt) {
+			delete(l.subjects, sub)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"app/internal/models"
+	"testing"
+	"time"
+)
+
+func TestMemoryRevocationListIsRevoked(t *testing.T) {
+	now := time.Now()
+	cutoff := now.Truncate(time.Second)
+
+	l := NewMemoryRevocationList()
+	l.RevokeToken("revoked-jti", now.Add(time.Hour))
+	l.RevokeToken("expired-jti", now.Add(-time.Second))
+	l.RevokeSubject("signed-out", cutoff, now.Add(time.Hour))
+	l.RevokeSubject("expired-sub", cutoff, now.Add(-time.Second))
+
+	tests := []struct {
+		name   string
+		claims models.Claims
+		want   bool
+	}{
+		{name: "unrelated token", claims: models.Claims{Sub: "other", Jti: "jti", IssuedAt: cutoff}, want: false},
+		{name: "revoked jti", claims: models.Claims{Sub: "other", Jti: "revoked-jti", IssuedAt: cutoff}, want: true},
+		{name: "revoked origin_jti", claims: models.Claims{Sub: "other", Jti: "jti", OriginJti: "revoked-jti", IssuedAt: cutoff}, want: true},
+		{name: "expired token entry", claims: models.Claims{Sub: "other", Jti: "expired-jti", IssuedAt: cutoff}, want: false},
+		{name: "issued before the cutoff", claims: models.Claims{Sub: "signed-out", IssuedAt: cutoff.Add(-time.Second)}, want: true},
+		{name: "issued in the cutoff second", claims: models.Claims{Sub: "signed-out", IssuedAt: cutoff}, want: true},
+		{name: "issued after the cutoff", claims: models.Claims{Sub: "signed-out", IssuedAt: cutoff.Add(time.Second)}, want: false},
+		{name: "no iat", claims: models.Claims{Sub: "signed-out"}, want: true},
+		{name: "expired subject entry", claims: models.Claims{Sub: "expired-sub", IssuedAt: cutoff}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.IsRevoked(&tt.claims); got != tt.want {
+				t.Errorf("IsRevoked(%+v) = %v, want %v", tt.claims, got, tt.want)
+			}
+		})
+	}
+}
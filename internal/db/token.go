@@ -0,0 +1,118 @@
+package db
+
+import (
+	"app/internal/config"
+	appError "app/internal/errors"
+	"app/internal/models"
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenValidator holds the claim checks shared by every AuthStore, so a token
+// is judged the same way whichever backend signed it. Each rejection is
+// reported with its own AuthError.
+type tokenValidator struct {
+	parser    *jwt.Parser
+	issuer    string
+	tokenUses map[string]bool
+	clientIds map[string]bool
+}
+
+// newTokenValidator accepts tokens from issuer that were issued to one of
+// cfg.AwsCognitoAllowedClientIds, or to clientId when no allowlist is set.
+func newTokenValidator(cfg *config.Config, issuer, clientId string) *tokenValidator {
+	tokenUses := map[string]bool{}
+	switch cfg.TokenUse {
+	case config.TokenUseID:
+		tokenUses["id"] = true
+	case config.TokenUseBoth:
+		tokenUses["access"] = true
+		tokenUses["id"] = true
+	default:
+		tokenUses["access"] = true
+	}
+
+	clientIds := map[string]bool{}
+	for _, id := range cfg.AwsCognitoAllowedClientIds {
+		clientIds[id] = true
+	}
+	if len(clientIds) == 0 {
+		clientIds[clientId] = true
+	}
+
+	return &tokenValidator{
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuedAt(),
+			jwt.WithLeeway(cfg.TokenLeeway),
+		),
+		issuer:    issuer,
+		tokenUses: tokenUses,
+		clientIds: clientIds,
+	}
+}
+
+func (v *tokenValidator) validate(tokenString string, keyFunc jwt.Keyfunc) (*jwt.Token, error) {
+	token, err := v.parser.Parse(tokenString, keyFunc)
+	if err != nil {
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, appError.NewTokenExpiredError().WithCause(err)
+		case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+			return nil, appError.NewInvalidTokenError("token is not valid yet").WithCause(err)
+		case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+			return nil, appError.NewInvalidTokenError("signature could not be verified").WithCause(err)
+		default:
+			return nil, appError.NewInvalidTokenError("malformed token").WithCause(err)
+		}
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, appError.NewInvalidTokenError("invalid claims")
+	}
+
+	issuer, err := claims.GetIssuer()
+	if err != nil || issuer != v.issuer {
+		return nil, appError.NewInvalidTokenIssuerError()
+	}
+
+	tokenUse, _ := claims["token_use"].(string)
+	if !v.tokenUses[tokenUse] {
+		return nil, appError.NewInvalidTokenUseError()
+	}
+
+	// Access tokens name the app client in client_id, ID tokens in aud.
+	if tokenUse == "access" {
+		clientId, _ := claims["client_id"].(string)
+		if !v.clientIds[clientId] {
+			return nil, appError.NewInvalidTokenAudienceError()
+		}
+	} else {
+		audience, err := claims.GetAudience()
+		if err != nil || !v.anyClientId(audience) {
+			return nil, appError.NewInvalidTokenAudienceError()
+		}
+	}
+
+	return token, nil
+}
+
+func (v *tokenValidator) anyClientId(audience []string) bool {
+	for _, aud := range audience {
+		if v.clientIds[aud] {
+			return true
+		}
+	}
+	return false
+}
+
+func claimsFromToken(token *jwt.Token) (*models.Claims, error) {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	return models.NewClaims(claims), nil
+}
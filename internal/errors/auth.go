@@ -13,12 +13,66 @@ var (
 	ErrPasswordReset      = errors.New("password reset required")
 	ErrInvalidCode        = errors.New("invalid confirmation code")
 	ErrExpiredCode        = errors.New("expired confirmation code")
+	ErrIncorrectPassword  = errors.New("incorrect password")
+	ErrInvalidPassword    = errors.New("invalid password")
+	ErrTooManyRequests    = errors.New("too many requests")
+	ErrAccountLocked      = errors.New("account locked")
+	ErrForbidden          = errors.New("forbidden")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrGroupNotFound      = errors.New("group not found")
+	ErrGroupExists        = errors.New("group already exists")
+
+	ErrInvalidToken         = errors.New("invalid token")
+	ErrTokenExpired         = errors.New("token expired")
+	ErrInvalidTokenIssuer   = errors.New("invalid token issuer")
+	ErrInvalidTokenUse      = errors.New("invalid token use")
+	ErrInvalidTokenAudience = errors.New("invalid token audience")
+	ErrRevokedToken         = errors.New("revoked token")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrExpiredRefreshToken = errors.New("expired refresh token")
+	ErrRevokedRefreshToken = errors.New("revoked refresh token")
 )
 
+// codes are the stable, machine-readable codes clients see for each sentinel.
+// They are part of the API, so existing ones must not change.
+var codes = map[error]string{
+	ErrInvalidCredentials: "INVALID_CREDENTIALS",
+	ErrInvalidInput:       "INVALID_INPUT",
+	ErrAccountExists:      "ACCOUNT_EXISTS",
+	ErrServiceUnavailable: "SERVICE_UNAVAILABLE",
+	ErrPasswordReset:      "PASSWORD_RESET_REQUIRED",
+	ErrInvalidCode:        "CODE_INVALID",
+	ErrExpiredCode:        "CODE_EXPIRED",
+	ErrIncorrectPassword:  "INCORRECT_PASSWORD",
+	ErrInvalidPassword:    "INVALID_PASSWORD",
+	ErrTooManyRequests:    "TOO_MANY_REQUESTS",
+	ErrAccountLocked:      "ACCOUNT_LOCKED",
+	ErrForbidden:          "FORBIDDEN",
+	ErrUserNotFound:       "USER_NOT_FOUND",
+	ErrGroupNotFound:      "GROUP_NOT_FOUND",
+	ErrGroupExists:        "GROUP_EXISTS",
+
+	ErrInvalidToken:         "TOKEN_INVALID",
+	ErrTokenExpired:         "TOKEN_EXPIRED",
+	ErrInvalidTokenIssuer:   "TOKEN_ISSUER_INVALID",
+	ErrInvalidTokenUse:      "TOKEN_USE_INVALID",
+	ErrInvalidTokenAudience: "TOKEN_AUDIENCE_INVALID",
+	ErrRevokedToken:         "TOKEN_REVOKED",
+
+	ErrInvalidRefreshToken: "REFRESH_TOKEN_INVALID",
+	ErrExpiredRefreshToken: "REFRESH_TOKEN_EXPIRED",
+	ErrRevokedRefreshToken: "REFRESH_TOKEN_REVOKED",
+}
+
+// AuthError is an error that is safe to show to clients. Message is picked
+// from the constructors below and never carries upstream error text; the
+// upstream error, when there is one, is kept in Cause for the logs.
 type AuthError struct {
 	StatusCode int
 	Err        error
 	Message    string
+	Cause      error
 }
 
 func (e *AuthError) Error() string {
@@ -32,6 +86,18 @@ func (e *AuthError) Unwrap() error {
 	return e.Err
 }
 
+// WithCause records the upstream error e stands for and returns e.
+func (e *AuthError) WithCause(cause error) *AuthError {
+	e.Cause = cause
+	return e
+}
+
+// Code returns the machine-readable code of the sentinel e wraps, or "" when
+// it has none.
+func (e *AuthError) Code() string {
+	return codes[e.Err]
+}
+
 func NewInvalidCredentialsError(detail string) *AuthError {
 	msg := "Invalid credentials"
 	if detail != "" {
@@ -103,3 +169,162 @@ func NewExpiredCodeError() *AuthError {
 		Message:    "Confirmation code has expired",
 	}
 }
+
+// NewIncorrectPasswordError is returned when a signed-in user re-enters a
+// password that doesn't match. It is a 403 rather than a 401 so clients don't
+// take it for an expired session.
+func NewIncorrectPasswordError() *AuthError {
+	return &AuthError{
+		StatusCode: 403,
+		Err:        ErrIncorrectPassword,
+		Message:    "Incorrect password",
+	}
+}
+
+func NewInvalidPasswordError(detail string) *AuthError {
+	msg := "Password does not meet the requirements"
+	if detail != "" {
+		msg = fmt.Sprintf("%s: %s", msg, detail)
+	}
+	return &AuthError{
+		StatusCode: 400,
+		Err:        ErrInvalidPassword,
+		Message:    msg,
+	}
+}
+
+func NewTooManyRequestsError(detail string) *AuthError {
+	msg := "Too many requests"
+	if detail != "" {
+		msg = fmt.Sprintf("%s: %s", msg, detail)
+	}
+	return &AuthError{
+		StatusCode: 429,
+		Err:        ErrTooManyRequests,
+		Message:    msg,
+	}
+}
+
+func NewAccountLockedError(detail string) *AuthError {
+	msg := "Account temporarily locked"
+	if detail != "" {
+		msg = fmt.Sprintf("%s: %s", msg, detail)
+	}
+	return &AuthError{
+		StatusCode: 429,
+		Err:        ErrAccountLocked,
+		Message:    msg,
+	}
+}
+
+func NewForbiddenError(detail string) *AuthError {
+	msg := "Forbidden"
+	if detail != "" {
+		msg = fmt.Sprintf("%s: %s", msg, detail)
+	}
+	return &AuthError{
+		StatusCode: 403,
+		Err:        ErrForbidden,
+		Message:    msg,
+	}
+}
+
+func NewUserNotFoundError() *AuthError {
+	return &AuthError{
+		StatusCode: 404,
+		Err:        ErrUserNotFound,
+		Message:    "User not found",
+	}
+}
+
+func NewGroupNotFoundError() *AuthError {
+	return &AuthError{
+		StatusCode: 404,
+		Err:        ErrGroupNotFound,
+		Message:    "Group not found",
+	}
+}
+
+func NewGroupExistsError() *AuthError {
+	return &AuthError{
+		StatusCode: 409,
+		Err:        ErrGroupExists,
+		Message:    "Group already exists",
+	}
+}
+
+func NewInvalidTokenError(detail string) *AuthError {
+	msg := "Invalid token"
+	if detail != "" {
+		msg = fmt.Sprintf("%s: %s", msg, detail)
+	}
+	return &AuthError{
+		StatusCode: 401,
+		Err:        ErrInvalidToken,
+		Message:    msg,
+	}
+}
+
+func NewTokenExpiredError() *AuthError {
+	return &AuthError{
+		StatusCode: 401,
+		Err:        ErrTokenExpired,
+		Message:    "Token has expired",
+	}
+}
+
+func NewInvalidTokenIssuerError() *AuthError {
+	return &AuthError{
+		StatusCode: 401,
+		Err:        ErrInvalidTokenIssuer,
+		Message:    "Token was not issued by the configured user pool",
+	}
+}
+
+func NewInvalidTokenUseError() *AuthError {
+	return &AuthError{
+		StatusCode: 401,
+		Err:        ErrInvalidTokenUse,
+		Message:    "Token type is not accepted",
+	}
+}
+
+func NewInvalidTokenAudienceError() *AuthError {
+	return &AuthError{
+		StatusCode: 401,
+		Err:        ErrInvalidTokenAudience,
+		Message:    "Token was not issued to an allowed client",
+	}
+}
+
+func NewRevokedTokenError() *AuthError {
+	return &AuthError{
+		StatusCode: 401,
+		Err:        ErrRevokedToken,
+		Message:    "Token has been revoked",
+	}
+}
+
+func NewInvalidRefreshTokenError() *AuthError {
+	return &AuthError{
+		StatusCode: 401,
+		Err:        ErrInvalidRefreshToken,
+		Message:    "Invalid refresh token",
+	}
+}
+
+func NewExpiredRefreshTokenError() *AuthError {
+	return &AuthError{
+		StatusCode: 401,
+		Err:        ErrExpiredRefreshToken,
+		Message:    "Refresh token has expired",
+	}
+}
+
+func NewRevokedRefreshTokenError() *AuthError {
+	return &AuthError{
+		StatusCode: 401,
+		Err:        ErrRevokedRefreshToken,
+		Message:    "Refresh token has been revoked",
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"app/internal/models"
+	"app/internal/services"
+	"app/internal/validation"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type adminHandlers struct {
+	svc       services.AdminServiceInterface
+	validator *validation.Validator
+}
+
+func NewAdminHandlers(svc services.AdminServiceInterface, validator *validation.Validator) *adminHandlers {
+	return &adminHandlers{
+		svc:       svc,
+		validator: validator,
+	}
+}
+
+func (h *adminHandlers) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := &models.ListUsersParams{
+		PaginationToken: query.Get("pagination_token"),
+		FilterAttribute: query.Get("filter_attribute"),
+		FilterValue:     query.Get("filter_value"),
+		FilterType:      query.Get("filter_type"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusBadRequest, "Please provide correct input"))
+			return
+		}
+		params.Limit = n
+	}
+
+	res, err := h.svc.ListUsers(r.Context(), params)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *adminHandlers) GetUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.svc.GetUser)
+}
+
+func (h *adminHandlers) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.svc.DisableUser)
+}
+
+func (h *adminHandlers) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.svc.EnableUser)
+}
+
+func (h *adminHandlers) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.svc.DeleteUser)
+}
+
+func (h *adminHandlers) ConfirmUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.svc.ConfirmUser)
+}
+
+func (h *adminHandlers) ResetUserPassword(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.svc.ResetUserPassword)
+}
+
+func (h *adminHandlers) SignOutUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.svc.SignOutUser)
+}
+
+func (h *adminHandlers) ListGroups(w http.ResponseWriter, r *http.Request) {
+	params, ok := listGroupsParams(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.svc.ListGroups(r.Context(), params)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *adminHandlers) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var body models.CreateGroupParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+
+	res, err := h.svc.CreateGroup(r.Context(), &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *adminHandlers) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	res, err := h.svc.DeleteGroup(r.Context(), chi.URLParam(r, "group"))
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *adminHandlers) AddUserToGroup(w http.ResponseWriter, r *http.Request) {
+	h.membershipAction(w, r, h.svc.AddUserToGroup)
+}
+
+func (h *adminHandlers) RemoveUserFromGroup(w http.ResponseWriter, r *http.Request) {
+	h.membershipAction(w, r, h.svc.RemoveUserFromGroup)
+}
+
+func (h *adminHandlers) ListUserGroups(w http.ResponseWriter, r *http.Request) {
+	params, ok := listGroupsParams(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.svc.ListUserGroups(r.Context(), chi.URLParam(r, "username"), params)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+// userAction calls action with the {username} URL parameter.
+func (h *adminHandlers) userAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse)) {
+	res, err := action(r.Context(), chi.URLParam(r, "username"))
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+// membershipAction calls action with the {username} and {group} URL
+// parameters.
+func (h *adminHandlers) membershipAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, username, group string) (*models.DataResponse, *models.ErrorResponse)) {
+	res, err := action(r.Context(), chi.URLParam(r, "username"), chi.URLParam(r, "group"))
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+// listGroupsParams reads the limit and next_token query parameters, writing a
+// 400 response when limit isn't a number.
+func listGroupsParams(w http.ResponseWriter, r *http.Request) (*models.ListGroupsParams, bool) {
+	query := r.URL.Query()
+	params := &models.ListGroupsParams{
+		NextToken: query.Get("next_token"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusBadRequest, "Please provide correct input"))
+			return nil, false
+		}
+		params.Limit = n
+	}
+	return params, true
+}
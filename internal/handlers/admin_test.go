@@ -0,0 +1,316 @@
+package handlers_test
+
+import (
+	"app/internal/config"
+	"app/internal/db"
+	"app/internal/handlers"
+	"app/internal/services"
+	"app/internal/validation"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/go-chi/chi/v5"
+)
+
+const cognitoTargetPrefix = "AWSCognitoIdentityProviderService."
+
+// cognitoStub answers Cognito API calls with canned responses, keyed by
+// operation name. It records the input of every call it receives.
+type cognitoStub struct {
+	t         *testing.T
+	responses map[string]cognitoResponse
+	calls     map[string]map[string]any
+}
+
+type cognitoResponse struct {
+	status int
+	body   any
+}
+
+func cognitoOK(body any) cognitoResponse {
+	return cognitoResponse{status: http.StatusOK, body: body}
+}
+
+func cognitoError(exception, message string) cognitoResponse {
+	return cognitoResponse{
+		status: http.StatusBadRequest,
+		body:   map[string]string{"__type": exception, "message": message},
+	}
+}
+
+func (s *cognitoStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/jwks" {
+		w.Write([]byte(`{"keys":[]}`))
+		return
+	}
+
+	op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), cognitoTargetPrefix)
+	var input map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		s.t.Errorf("%s: failed to decode input: %v", op, err)
+	}
+	s.calls[op] = input
+
+	res, ok := s.responses[op]
+	if !ok {
+		s.t.Errorf("unexpected Cognito call %s", op)
+		res = cognitoError("InternalErrorException", "unexpected call")
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(res.status)
+	json.NewEncoder(w).Encode(res.body)
+}
+
+func newAdminRouter(t *testing.T, stub *cognitoStub) http.Handler {
+	t.Helper()
+
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+
+	store, err := db.NewCognitoStore(&config.Config{
+		AwsCognitoUserPoolId:   "us-east-1_test",
+		AwsCognitoClientId:     "client",
+		AwsTokenURL:            srv.URL + "/jwks",
+		JwksRefreshInterval:    time.Hour,
+		JwksMinRefetchInterval: time.Minute,
+		AwsConfig: aws.Config{
+			Region:           "us-east-1",
+			Credentials:      aws.AnonymousCredentials{},
+			BaseEndpoint:     aws.String(srv.URL),
+			RetryMaxAttempts: 1,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	h := handlers.NewAdminHandlers(
+		services.NewAdminService(store, db.NewMemoryRevocationList()),
+		validation.New(config.PasswordPolicy{}),
+	)
+	router := chi.NewRouter()
+	router.Get("/admin/groups", h.ListGroups)
+	router.Post("/admin/groups", h.CreateGroup)
+	router.Delete("/admin/groups/{group}", h.DeleteGroup)
+	router.Get("/admin/users/{username}/groups", h.ListUserGroups)
+	router.Post("/admin/users/{username}/groups/{group}", h.AddUserToGroup)
+	router.Delete("/admin/users/{username}/groups/{group}", h.RemoveUserFromGroup)
+	return router
+}
+
+func TestGroupHandlers(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		responses  map[string]cognitoResponse
+		wantStatus int
+		wantBody   string
+		wantInput  map[string]map[string]any
+	}{
+		{
+			name:   "list groups",
+			method: http.MethodGet,
+			path:   "/admin/groups?limit=2&next_token=abc",
+			responses: map[string]cognitoResponse{
+				"ListGroups": cognitoOK(map[string]any{
+					"Groups": []map[string]any{
+						{"GroupName": "admin", "Description": "Administrators", "Precedence": 1},
+						{"GroupName": "staff"},
+					},
+					"NextToken": "def",
+				}),
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"next_token":"def"`,
+			wantInput: map[string]map[string]any{
+				"ListGroups": {"UserPoolId": "us-east-1_test", "Limit": float64(2), "NextToken": "abc"},
+			},
+		},
+		{
+			name:       "list groups with bad limit",
+			method:     http.MethodGet,
+			path:       "/admin/groups?limit=many",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "list groups with limit too large",
+			method:     http.MethodGet,
+			path:       "/admin/groups?limit=61",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "create group",
+			method: http.MethodPost,
+			path:   "/admin/groups",
+			body:   `{"name":"staff","description":"Staff","precedence":5}`,
+			responses: map[string]cognitoResponse{
+				"CreateGroup": cognitoOK(map[string]any{
+					"Group": map[string]any{"GroupName": "staff", "Description": "Staff", "Precedence": 5},
+				}),
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   `"name":"staff"`,
+			wantInput: map[string]map[string]any{
+				"CreateGroup": {"UserPoolId": "us-east-1_test", "GroupName": "staff", "Description": "Staff", "Precedence": float64(5)},
+			},
+		},
+		{
+			name:       "create group without name",
+			method:     http.MethodPost,
+			path:       "/admin/groups",
+			body:       `{"description":"Staff"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `{"field":"name","message":"is required"}`,
+		},
+		{
+			name:       "create group with unknown field",
+			method:     http.MethodPost,
+			path:       "/admin/groups",
+			body:       `{"name":"staff","role":"admin"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "create existing group",
+			method: http.MethodPost,
+			path:   "/admin/groups",
+			body:   `{"name":"staff"}`,
+			responses: map[string]cognitoResponse{
+				"CreateGroup": cognitoError("GroupExistsException", "A group with the name already exists."),
+			},
+			wantStatus: http.StatusConflict,
+			wantBody:   `"code":"GROUP_EXISTS"`,
+		},
+		{
+			name:   "delete group",
+			method: http.MethodDelete,
+			path:   "/admin/groups/staff",
+			responses: map[string]cognitoResponse{
+				"DeleteGroup": cognitoOK(map[string]any{}),
+			},
+			wantStatus: http.StatusOK,
+			wantInput: map[string]map[string]any{
+				"DeleteGroup": {"UserPoolId": "us-east-1_test", "GroupName": "staff"},
+			},
+		},
+		{
+			name:   "delete missing group",
+			method: http.MethodDelete,
+			path:   "/admin/groups/staff",
+			responses: map[string]cognitoResponse{
+				"DeleteGroup": cognitoError("ResourceNotFoundException", "Group not found."),
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "add user to group",
+			method: http.MethodPost,
+			path:   "/admin/users/alice/groups/staff",
+			responses: map[string]cognitoResponse{
+				"AdminAddUserToGroup": cognitoOK(map[string]any{}),
+			},
+			wantStatus: http.StatusOK,
+			wantInput: map[string]map[string]any{
+				"AdminAddUserToGroup": {"UserPoolId": "us-east-1_test", "Username": "alice", "GroupName": "staff"},
+			},
+		},
+		{
+			name:   "add missing user to group",
+			method: http.MethodPost,
+			path:   "/admin/users/alice/groups/staff",
+			responses: map[string]cognitoResponse{
+				"AdminAddUserToGroup": cognitoError("UserNotFoundException", "User does not exist."),
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "remove user from group",
+			method: http.MethodDelete,
+			path:   "/admin/users/alice/groups/staff",
+			responses: map[string]cognitoResponse{
+				"AdminRemoveUserFromGroup": cognitoOK(map[string]any{}),
+			},
+			wantStatus: http.StatusOK,
+			wantInput: map[string]map[string]any{
+				"AdminRemoveUserFromGroup": {"UserPoolId": "us-east-1_test", "Username": "alice", "GroupName": "staff"},
+			},
+		},
+		{
+			name:   "remove user from missing group",
+			method: http.MethodDelete,
+			path:   "/admin/users/alice/groups/staff",
+			responses: map[string]cognitoResponse{
+				"AdminRemoveUserFromGroup": cognitoError("ResourceNotFoundException", "Group not found."),
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "list user groups",
+			method: http.MethodGet,
+			path:   "/admin/users/alice/groups",
+			responses: map[string]cognitoResponse{
+				"AdminListGroupsForUser": cognitoOK(map[string]any{
+					"Groups": []map[string]any{{"GroupName": "staff"}},
+				}),
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"staff"`,
+			wantInput: map[string]map[string]any{
+				"AdminListGroupsForUser": {"UserPoolId": "us-east-1_test", "Username": "alice"},
+			},
+		},
+		{
+			name:   "throttled",
+			method: http.MethodGet,
+			path:   "/admin/users/alice/groups",
+			responses: map[string]cognitoResponse{
+				"AdminListGroupsForUser": cognitoError("TooManyRequestsException", "Rate exceeded"),
+			},
+			wantStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &cognitoStub{
+				t:         t,
+				responses: tt.responses,
+				calls:     make(map[string]map[string]any),
+			}
+			router := newAdminRouter(t, stub)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			body, _ := io.ReadAll(rec.Body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.wantStatus, body)
+			}
+			if tt.wantBody != "" && !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+
+			for op, want := range tt.wantInput {
+				got, ok := stub.calls[op]
+				if !ok {
+					t.Errorf("%s was not called", op)
+					continue
+				}
+				for key, value := range want {
+					if got[key] != value {
+						t.Errorf("%s input %s = %v, want %v", op, key, got[key], value)
+					}
+				}
+			}
+		})
+	}
+}
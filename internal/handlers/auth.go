@@ -3,67 +3,169 @@ package handlers
 import (
 	"app/internal/models"
 	"app/internal/services"
-	"encoding/json"
+	"app/internal/validation"
 	"net/http"
 )
 
 type authHandlers struct {
-	svc services.AuthServiceInterface
+	svc       services.AuthServiceInterface
+	validator *validation.Validator
 }
 
-func NewAuthHandlers(svc services.AuthServiceInterface) *authHandlers {
+func NewAuthHandlers(svc services.AuthServiceInterface, validator *validation.Validator) *authHandlers {
 	return &authHandlers{
-		svc: svc,
+		svc:       svc,
+		validator: validator,
 	}
 }
 
 func (h *authHandlers) SignUp(w http.ResponseWriter, r *http.Request) {
-	var body *models.User
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		models.ResponseWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(http.StatusBadRequest, "Please provide correct input"))
+	var body models.User
+	if !decodeBody(w, r, h.validator, &body) {
 		return
 	}
-	res, err := h.svc.SignUp(r.Context(), body)
+	res, err := h.svc.SignUp(r.Context(), &body)
 	if err != nil {
-		models.ResponseWithJSON(w, err.Status, err)
+		models.ResponseWithError(w, r, err)
 		return
 	}
 	models.ResponseWithJSON(w, res.Status, res)
 }
 
 func (h *authHandlers) Login(w http.ResponseWriter, r *http.Request) {
-	var body *models.UserLoginParams
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		models.ResponseWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(http.StatusBadRequest, "Please provide correct input"))
+	var body models.UserLoginParams
+	if !decodeBody(w, r, h.validator, &body) {
 		return
 	}
-	res, err := h.svc.Login(r.Context(), body)
+	res, err := h.svc.Login(r.Context(), &body)
 	if err != nil {
-		models.ResponseWithJSON(w, err.Status, err)
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) RespondToChallenge(w http.ResponseWriter, r *http.Request) {
+	var body models.UserChallengeParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.RespondToChallenge(r.Context(), &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
 		return
 	}
 	models.ResponseWithJSON(w, res.Status, res)
 }
 
 func (h *authHandlers) ConfirmAccount(w http.ResponseWriter, r *http.Request) {
-	var body *models.UserConfirmationParams
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		models.ResponseWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(http.StatusBadRequest, "Please provide correct input"))
+	var body models.UserConfirmationParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.ConfirmAccount(r.Context(), &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) ResendConfirmationCode(w http.ResponseWriter, r *http.Request) {
+	var body models.UserResendCodeParams
+	if !decodeBody(w, r, h.validator, &body) {
 		return
 	}
-	res, err := h.svc.ConfirmAccount(r.Context(), body)
+	res, err := h.svc.ResendConfirmationCode(r.Context(), &body)
 	if err != nil {
-		models.ResponseWithJSON(w, err.Status, err)
+		models.ResponseWithError(w, r, err)
 		return
 	}
 	models.ResponseWithJSON(w, res.Status, res)
 }
 
 func (h *authHandlers) GetUser(w http.ResponseWriter, r *http.Request) {
-	reqCtx := r.Context().Value(models.RequestContextKey).(*models.RequestContext)
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
 	res, err := h.svc.GetUser(r.Context(), reqCtx.Token)
 	if err != nil {
-		models.ResponseWithJSON(w, err.Status, err)
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) Refresh(w http.ResponseWriter, r *http.Request) {
+	var body models.UserRefreshParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.Refresh(r.Context(), &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var body models.UserForgotPasswordParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.ForgotPassword(r.Context(), &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var body models.UserResetPasswordParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.ResetPassword(r.Context(), &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) Logout(w http.ResponseWriter, r *http.Request) {
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
+
+	var body models.UserLogoutParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.Logout(r.Context(), reqCtx.Claims, &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
+
+	res, err := h.svc.LogoutAll(r.Context(), reqCtx.Token, reqCtx.Claims)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
 		return
 	}
 	models.ResponseWithJSON(w, res.Status, res)
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"app/internal/models"
+	"net/http"
+)
+
+func (h *authHandlers) StartMFAEnrollment(w http.ResponseWriter, r *http.Request) {
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
+
+	res, err := h.svc.StartMFAEnrollment(r.Context(), reqCtx.Token, reqCtx.Claims)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) VerifyMFAEnrollment(w http.ResponseWriter, r *http.Request) {
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
+
+	var body models.MFAVerifyParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.VerifyMFAEnrollment(r.Context(), reqCtx.Token, &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) SetMFAPreference(w http.ResponseWriter, r *http.Request) {
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
+
+	var body models.MFAPreferenceParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.SetMFAPreference(r.Context(), reqCtx.Token, &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
+
+	res, err := h.svc.DisableMFA(r.Context(), reqCtx.Token)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"app/internal/models"
+	"net/http"
+)
+
+func (h *authHandlers) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
+
+	var body models.UserUpdateParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.UpdateUser(r.Context(), reqCtx.Token, &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) SendAttributeVerification(w http.ResponseWriter, r *http.Request) {
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
+
+	var body models.UserVerifyAttributeParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.SendAttributeVerification(r.Context(), reqCtx.Token, &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) ConfirmAttribute(w http.ResponseWriter, r *http.Request) {
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
+
+	var body models.UserConfirmAttributeParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.ConfirmAttribute(r.Context(), reqCtx.Token, &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
+
+	var body models.UserChangePasswordParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.ChangePassword(r.Context(), reqCtx.Token, reqCtx.Claims, &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
+
+func (h *authHandlers) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	reqCtx, ok := models.FromContext(r.Context())
+	if !ok {
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusUnauthorized, "Authorization required"))
+		return
+	}
+
+	var body models.UserDeleteParams
+	if !decodeBody(w, r, h.validator, &body) {
+		return
+	}
+	res, err := h.svc.DeleteUser(r.Context(), reqCtx.Token, reqCtx.Claims, &body)
+	if err != nil {
+		models.ResponseWithError(w, r, err)
+		return
+	}
+	models.ResponseWithJSON(w, res.Status, res)
+}
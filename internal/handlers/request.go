@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"app/internal/models"
+	"app/internal/validation"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// decodeBody reads a JSON request body into dst and validates it. When the
+// body can't be used it writes the error response and returns false: 413 for
+// a body over the size limit, 400 for anything but a single JSON object with
+// known fields, and 422 listing the fields that failed validation.
+func decodeBody(w http.ResponseWriter, r *http.Request, validator *validation.Validator, dst any) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("unexpected data after JSON object")
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusRequestEntityTooLarge, "Request body too large"))
+			return false
+		}
+		models.ResponseWithError(w, r, models.NewErrorResponse(http.StatusBadRequest, "Please provide correct input"))
+		return false
+	}
+
+	if fields := validator.Validate(dst); len(fields) > 0 {
+		models.ResponseWithError(w, r, models.NewValidationErrorResponse(fields))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// ListUsersParams pages through the users of the pool. When FilterAttribute is
+// set only users whose attribute equals FilterValue, or starts with it when
+// FilterType is "prefix", are returned.
+type ListUsersParams struct {
+	Limit           int
+	PaginationToken string
+	FilterAttribute string
+	FilterValue     string
+	FilterType      string
+}
+
+const (
+	FilterTypeExact  = "exact"
+	FilterTypePrefix = "prefix"
+)
+
+type AdminUser struct {
+	Username   string            `json:"username"`
+	Attributes map[string]string `json:"attributes"`
+	Enabled    bool              `json:"enabled"`
+	Status     string            `json:"status"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+type ListUsersResponse struct {
+	Users           []AdminUser `json:"users"`
+	PaginationToken string      `json:"pagination_token,omitempty"`
+}
+
+type Group struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Precedence  *int32    `json:"precedence,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type CreateGroupParams struct {
+	Name        string `json:"name" validate:"required,max=128"`
+	Description string `json:"description" validate:"max=2048"`
+	Precedence  *int32 `json:"precedence"`
+}
+
+type ListGroupsParams struct {
+	Limit     int
+	NextToken string
+}
+
+type ListGroupsResponse struct {
+	Groups    []Group `json:"groups"`
+	NextToken string  `json:"next_token,omitempty"`
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Claims is the typed view of a validated Cognito token. Claims that have no
+// field of their own are still available in Raw.
+type Claims struct {
+	Sub       string
+	Username  string
+	Email     string
+	Groups    []string
+	Scopes    []string
+	TokenUse  string
+	ClientID  string
+	AuthTime  time.Time
+	IssuedAt  time.Time
+	Exp       time.Time
+	Jti       string
+	OriginJti string
+	Raw       map[string]interface{}
+}
+
+// NewClaims reads the claims Cognito puts in access and ID tokens. Access
+// tokens carry username and client_id, ID tokens cognito:username and aud.
+func NewClaims(raw map[string]interface{}) *Claims {
+	claims := &Claims{
+		Sub:       stringClaim(raw, "sub"),
+		Username:  stringClaim(raw, "username"),
+		Email:     stringClaim(raw, "email"),
+		Groups:    stringsClaim(raw, "cognito:groups"),
+		Scopes:    strings.Fields(stringClaim(raw, "scope")),
+		TokenUse:  stringClaim(raw, "token_use"),
+		ClientID:  stringClaim(raw, "client_id"),
+		AuthTime:  timeClaim(raw, "auth_time"),
+		IssuedAt:  timeClaim(raw, "iat"),
+		Exp:       timeClaim(raw, "exp"),
+		Jti:       stringClaim(raw, "jti"),
+		OriginJti: stringClaim(raw, "origin_jti"),
+		Raw:       raw,
+	}
+
+	if claims.Username == "" {
+		claims.Username = stringClaim(raw, "cognito:username")
+	}
+	if claims.ClientID == "" {
+		if aud := stringsClaim(raw, "aud"); len(aud) > 0 {
+			claims.ClientID = aud[0]
+		}
+	}
+
+	return claims
+}
+
+func (c *Claims) HasGroup(group string) bool {
+	for _, g := range c.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func stringClaim(raw map[string]interface{}, name string) string {
+	value, _ := raw[name].(string)
+	return value
+}
+
+// stringsClaim reads a claim that may hold either a single string or a list,
+// as aud and cognito:groups do depending on the token.
+func stringsClaim(raw map[string]interface{}, name string) []string {
+	switch value := raw[name].(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func timeClaim(raw map[string]interface{}, name string) time.Time {
+	switch value := raw[name].(type) {
+	case float64:
+		return time.Unix(int64(value), 0)
+	case int64:
+		return time.Unix(value, 0)
+	case int:
+		return time.Unix(int64(value), 0)
+	default:
+		return time.Time{}
+	}
+}
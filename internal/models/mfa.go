@@ -0,0 +1,23 @@
+package models
+
+type MFAEnrollmentResponse struct {
+	SecretCode string `json:"secret_code"`
+	URI        string `json:"uri"`
+}
+
+type MFAVerifyParams struct {
+	Code       string `json:"code" validate:"required,code"`
+	DeviceName string `json:"device_name" validate:"max=128"`
+}
+
+type MFASettings struct {
+	Enabled   bool `json:"enabled"`
+	Preferred bool `json:"preferred"`
+}
+
+// MFAPreferenceParams updates the MFA methods of the current user. A method
+// left out of the request keeps its current settings.
+type MFAPreferenceParams struct {
+	TOTP *MFASettings `json:"totp,omitempty"`
+	SMS  *MFASettings `json:"sms,omitempty"`
+}
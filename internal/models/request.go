@@ -1,10 +1,48 @@
 package models
 
+import "context"
+
 type contextKey string
 
-const RequestContextKey contextKey = "requestContext"
+const (
+	RequestContextKey contextKey = "requestContext"
+	errorDetailsKey   contextKey = "errorDetails"
+	clientIPKey       contextKey = "clientIP"
+)
 
 type RequestContext struct {
-	UserInfo interface{}
-	Token    string
+	Claims *Claims
+	Token  string
+}
+
+// FromContext returns the RequestContext set by the auth middleware, or false
+// when the request did not go through it.
+func FromContext(ctx context.Context) (*RequestContext, bool) {
+	reqCtx, ok := ctx.Value(RequestContextKey).(*RequestContext)
+	if !ok || reqCtx == nil || reqCtx.Claims == nil {
+		return nil, false
+	}
+	return reqCtx, true
+}
+
+// WithErrorDetails marks ctx as allowed to see the upstream error behind an
+// ErrorResponse. It is meant for local development only.
+func WithErrorDetails(ctx context.Context) context.Context {
+	return context.WithValue(ctx, errorDetailsKey, true)
+}
+
+func errorDetailsAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(errorDetailsKey).(bool)
+	return allowed
+}
+
+// WithClientIP records the address of the client that sent the request.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey, ip)
+}
+
+// ClientIP returns the address set by WithClientIP, or "" when there is none.
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey).(string)
+	return ip
 }
@@ -1,13 +1,51 @@
 package models
 
 import (
+	appError "app/internal/errors"
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
 )
 
+const problemJSON = "application/problem+json"
+
+// ErrorResponse is the body of every failed request. Code is a stable,
+// machine-readable name for the error that clients can switch on instead of
+// Error, which is meant for people and may change. The error behind the
+// response is logged, and only sent back in Debug when the request allows
+// error details.
 type ErrorResponse struct {
-	Status int    `json:"status"`
-	Error  string `json:"error"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Error     string       `json:"error"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Debug     string       `json:"debug,omitempty"`
+
+	cause error
+}
+
+// FieldError describes why one field of a request failed validation. Field is
+// the JSON name of the field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// problemResponse is an ErrorResponse in the RFC 7807 format, with code,
+// details and request_id as extension members.
+type problemResponse struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Code      string       `json:"code"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Debug     string       `json:"debug,omitempty"`
 }
 
 type DataResponse struct {
@@ -22,15 +60,111 @@ func NewDataResponse(status int, data any) *DataResponse {
 	}
 }
 
+// NewErrorResponse reports an error that has no AuthError behind it, so its
+// code is derived from the status.
 func NewErrorResponse(status int, err string) *ErrorResponse {
 	return &ErrorResponse{
 		Status: status,
+		Code:   statusCode(status),
 		Error:  err,
 	}
 }
 
+func NewAuthErrorResponse(err *appError.AuthError) *ErrorResponse {
+	code := err.Code()
+	if code == "" {
+		code = statusCode(err.StatusCode)
+	}
+	return &ErrorResponse{
+		Status: err.StatusCode,
+		Code:   code,
+		Error:  err.Error(),
+		cause:  err.Cause,
+	}
+}
+
+// WithCause records the error res stands for and returns res.
+func (res *ErrorResponse) WithCause(err error) *ErrorResponse {
+	res.cause = err
+	return res
+}
+
+// NewValidationErrorResponse reports the fields that failed validation with a
+// 422.
+func NewValidationErrorResponse(fields []FieldError) *ErrorResponse {
+	return &ErrorResponse{
+		Status:  http.StatusUnprocessableEntity,
+		Code:    "VALIDATION_FAILED",
+		Error:   "Validation failed",
+		Details: fields,
+	}
+}
+
 func ResponseWithJSON(w http.ResponseWriter, status int, payload any) {
 	w.Header().Set("Content-type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(payload)
 }
+
+// ResponseWithError writes res tagged with the ID of request r, logging the
+// error behind it. Clients that accept application/problem+json get it as an
+// RFC 7807 problem.
+func ResponseWithError(w http.ResponseWriter, r *http.Request, res *ErrorResponse) {
+	res.RequestID = middleware.GetReqID(r.Context())
+
+	if res.cause != nil {
+		level := slog.LevelWarn
+		if res.Status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(r.Context(), level, "request failed", "status", res.Status, "code", res.Code, "err", res.cause)
+
+		if errorDetailsAllowed(r.Context()) {
+			res.Debug = res.cause.Error()
+		}
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), problemJSON) {
+		ResponseWithJSON(w, res.Status, res)
+		return
+	}
+
+	w.Header().Set("Content-type", problemJSON)
+	w.WriteHeader(res.Status)
+	json.NewEncoder(w).Encode(&problemResponse{
+		Type:      "about:blank",
+		Title:     http.StatusText(res.Status),
+		Status:    res.Status,
+		Detail:    res.Error,
+		Code:      res.Code,
+		Details:   res.Details,
+		RequestID: res.RequestID,
+		Debug:     res.Debug,
+	})
+}
+
+// statusCode is the code of errors that only have an HTTP status.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "BAD_REQUEST"
+	case http.StatusUnauthorized:
+		return "UNAUTHORIZED"
+	case http.StatusForbidden:
+		return "FORBIDDEN"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "CONFLICT"
+	case http.StatusRequestEntityTooLarge:
+		return "PAYLOAD_TOO_LARGE"
+	case http.StatusUnprocessableEntity:
+		return "VALIDATION_FAILED"
+	case http.StatusTooManyRequests:
+		return "TOO_MANY_REQUESTS"
+	case http.StatusServiceUnavailable:
+		return "SERVICE_UNAVAILABLE"
+	default:
+		return "INTERNAL_ERROR"
+	}
+}
@@ -1,19 +1,107 @@
 package models
 
+// User registers a new account. Attributes holds any other standard
+// attributes to set on it, such as phone_number or given_name, keyed by their
+// Cognito name; Name and Email take precedence over the same keys there.
 type User struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name       string            `json:"name" validate:"required,max=256"`
+	Email      string            `json:"email" validate:"required,max=254,email"`
+	Password   string            `json:"password" validate:"required,max=256,password"`
+	Attributes map[string]string `json:"attributes,omitempty" validate:"max=20"`
 }
 
 type UserLoginParams struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,max=254,email"`
+	Password string `json:"password" validate:"required,max=256"`
 }
 
 type UserConfirmationParams struct {
-	Email string `json:"email"`
-	Code  string `json:"code"`
+	Email string `json:"email" validate:"required,max=254,email"`
+	Code  string `json:"code" validate:"required,code"`
+}
+
+type UserResendCodeParams struct {
+	Email string `json:"email" validate:"required,max=254,email"`
+}
+
+type UserForgotPasswordParams struct {
+	Email string `json:"email" validate:"required,max=254,email"`
+}
+
+type UserResetPasswordParams struct {
+	Email    string `json:"email" validate:"required,max=254,email"`
+	Code     string `json:"code" validate:"required,code"`
+	Password string `json:"password" validate:"required,max=256,password"`
+}
+
+// UserRefreshParams carries the refresh token issued at login. Username must be
+// the Cognito username (the access token's "username" claim) because the secret
+// hash for REFRESH_TOKEN_AUTH is computed from it, which is not the email when
+// the pool uses email as a sign-in alias.
+type UserRefreshParams struct {
+	Username     string `json:"username" validate:"required,max=256"`
+	RefreshToken string `json:"refresh_token" validate:"required,max=8192"`
+}
+
+// UserChallengeParams answers a challenge returned by login. Username is the
+// email used to log in, or USER_ID_FOR_SRP from the challenge parameters when
+// the pool signs users in by alias. Only the fields the challenge asks for
+// need to be set: NewPassword (and any required Attributes) for
+// NEW_PASSWORD_REQUIRED, Code for SMS_MFA and SOFTWARE_TOKEN_MFA, and MFAType
+// for SELECT_MFA_TYPE.
+type UserChallengeParams struct {
+	Username      string            `json:"username" validate:"required,max=256"`
+	ChallengeName string            `json:"challenge_name" validate:"required,max=64"`
+	Session       string            `json:"session" validate:"required,max=4096"`
+	NewPassword   string            `json:"new_password,omitempty" validate:"omitempty,max=256,password"`
+	Code          string            `json:"code,omitempty" validate:"omitempty,code"`
+	MFAType       string            `json:"mfa_type,omitempty" validate:"omitempty,max=32"`
+	Attributes    map[string]string `json:"attributes,omitempty" validate:"max=20"`
+}
+
+type UserLogoutParams struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=8192"`
+}
+
+type UserChangePasswordParams struct {
+	CurrentPassword string `json:"current_password" validate:"required,max=256"`
+	NewPassword     string `json:"new_password" validate:"required,max=256,password"`
+}
+
+// UserDeleteParams confirms the deletion of the signed-in user's account with
+// their password.
+type UserDeleteParams struct {
+	Password string `json:"password" validate:"required,max=256"`
+}
+
+// UserUpdateParams changes attributes of the signed-in user, keyed by their
+// Cognito name.
+type UserUpdateParams struct {
+	Attributes map[string]string `json:"attributes" validate:"required,max=20"`
+}
+
+type UserVerifyAttributeParams struct {
+	Attribute string `json:"attribute" validate:"required,max=32"`
+}
+
+type UserConfirmAttributeParams struct {
+	Attribute string `json:"attribute" validate:"required,max=32"`
+	Code      string `json:"code" validate:"required,code"`
+}
+
+// CodeDelivery tells where a verification code for Attribute was sent.
+// Destination is masked the way Cognito masks it.
+type CodeDelivery struct {
+	Attribute   string `json:"attribute"`
+	Medium      string `json:"medium"`
+	Destination string `json:"destination"`
+}
+
+// UserUpdateResponse lists the changed attributes that have to be verified
+// with the code sent for each before they count as verified again.
+type UserUpdateResponse struct {
+	Message       string         `json:"message"`
+	Verifications []CodeDelivery `json:"verifications,omitempty"`
 }
 
 type UserInfoResponse struct {
@@ -21,10 +109,19 @@ type UserInfoResponse struct {
 	Username   string            `json:"username"`
 }
 
+// AuthLoginResponse holds either the issued tokens or, when Cognito needs
+// more from the user before it issues them, the challenge to answer.
 type AuthLoginResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	ExpiresIn    int    `json:"expires_in"`
+	AccessToken  string         `json:"access_token,omitempty"`
+	RefreshToken string         `json:"refresh_token,omitempty"`
+	ExpiresIn    int            `json:"expires_in,omitempty"`
+	Challenge    *AuthChallenge `json:"challenge,omitempty"`
+}
+
+type AuthChallenge struct {
+	Name       string            `json:"name"`
+	Session    string            `json:"session"`
+	Parameters map[string]string `json:"parameters,omitempty"`
 }
 
 func NewAuthLoginResponse(accessToken, refreshToken string, expiresIn int) *AuthLoginResponse {
@@ -34,3 +131,13 @@ func NewAuthLoginResponse(accessToken, refreshToken string, expiresIn int) *Auth
 		ExpiresIn:    expiresIn,
 	}
 }
+
+func NewAuthChallengeResponse(name, session string, parameters map[string]string) *AuthLoginResponse {
+	return &AuthLoginResponse{
+		Challenge: &AuthChallenge{
+			Name:       name,
+			Session:    session,
+			Parameters: parameters,
+		},
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"app/internal/db"
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const maxListUsersLimit = 60
+
+// maxAccessTokenLifetime is the longest access token validity Cognito allows,
+// so a subject revoked for this long outlives every token issued before.
+const maxAccessTokenLifetime = 24 * time.Hour
+
+// listUsersFilterAttributes are the attributes Cognito's ListUsers can filter
+// on.
+var listUsersFilterAttributes = map[string]bool{
+	"username":            true,
+	"email":               true,
+	"phone_number":        true,
+	"name":                true,
+	"given_name":          true,
+	"family_name":         true,
+	"preferred_username":  true,
+	"cognito:user_status": true,
+	"status":              true,
+	"sub":                 true,
+}
+
+type AdminService struct {
+	store       db.AuthStore
+	revocations db.RevocationList
+}
+
+func NewAdminService(store db.AuthStore, revocations db.RevocationList) *AdminService {
+	return &AdminService{
+		store:       store,
+		revocations: revocations,
+	}
+}
+
+func (s *AdminService) ListUsers(ctx context.Context, params *models.ListUsersParams) (*models.DataResponse, *models.ErrorResponse) {
+	if params.Limit < 0 || params.Limit > maxListUsersLimit {
+		return nil, errorResponse(appError.NewInvalidInputError("limit must be between 1 and 60"), "")
+	}
+	if params.FilterAttribute != "" && !listUsersFilterAttributes[params.FilterAttribute] {
+		return nil, errorResponse(appError.NewInvalidInputError("Unsupported filter attribute"), "")
+	}
+	if params.FilterType != "" && params.FilterType != models.FilterTypeExact && params.FilterType != models.FilterTypePrefix {
+		return nil, errorResponse(appError.NewInvalidInputError("filter_type must be exact or prefix"), "")
+	}
+
+	res, err := s.store.ListUsers(ctx, params)
+	if err != nil {
+		return nil, errorResponse(err, "Failed to list users")
+	}
+
+	return models.NewDataResponse(http.StatusOK, res), nil
+}
+
+func (s *AdminService) GetUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse) {
+	res, err := s.store.AdminGetUser(ctx, username)
+	if err != nil {
+		return nil, errorResponse(err, "Failed to fetch user")
+	}
+
+	return models.NewDataResponse(http.StatusOK, res), nil
+}
+
+func (s *AdminService) DisableUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse) {
+	return s.revokingAction(ctx, username, s.store.AdminDisableUser, "Failed to disable user", "User disabled.")
+}
+
+func (s *AdminService) EnableUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse) {
+	if err := s.store.AdminEnableUser(ctx, username); err != nil {
+		return nil, errorResponse(err, "Failed to enable user")
+	}
+	return messageResponse("User enabled."), nil
+}
+
+func (s *AdminService) DeleteUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse) {
+	return s.revokingAction(ctx, username, s.store.AdminDeleteUser, "Failed to delete user", "User deleted.")
+}
+
+func (s *AdminService) ConfirmUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse) {
+	if err := s.store.AdminConfirmSignUp(ctx, username); err != nil {
+		return nil, errorResponse(err, "Failed to confirm user")
+	}
+	return messageResponse("User confirmed."), nil
+}
+
+func (s *AdminService) ResetUserPassword(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse) {
+	if err := s.store.AdminResetUserPassword(ctx, username); err != nil {
+		return nil, errorResponse(err, "Failed to reset password")
+	}
+	return messageResponse("Password reset. The user has been sent a code to choose a new one."), nil
+}
+
+func (s *AdminService) SignOutUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse) {
+	return s.revokingAction(ctx, username, s.store.AdminUserGlobalSignOut, "Failed to sign out user", "User signed out of all sessions.")
+}
+
+func (s *AdminService) ListGroups(ctx context.Context, params *models.ListGroupsParams) (*models.DataResponse, *models.ErrorResponse) {
+	if params.Limit < 0 || params.Limit > maxListUsersLimit {
+		return nil, errorResponse(appError.NewInvalidInputError("limit must be between 1 and 60"), "")
+	}
+
+	res, err := s.store.ListGroups(ctx, params)
+	if err != nil {
+		return nil, errorResponse(err, "Failed to list groups")
+	}
+
+	return models.NewDataResponse(http.StatusOK, res), nil
+}
+
+func (s *AdminService) CreateGroup(ctx context.Context, params *models.CreateGroupParams) (*models.DataResponse, *models.ErrorResponse) {
+	if params.Name == "" {
+		return nil, errorResponse(appError.NewInvalidInputError("Group name is required"), "")
+	}
+
+	res, err := s.store.CreateGroup(ctx, params)
+	if err != nil {
+		return nil, errorResponse(err, "Failed to create group")
+	}
+
+	return models.NewDataResponse(http.StatusCreated, res), nil
+}
+
+func (s *AdminService) DeleteGroup(ctx context.Context, name string) (*models.DataResponse, *models.ErrorResponse) {
+	if err := s.store.DeleteGroup(ctx, name); err != nil {
+		return nil, errorResponse(err, "Failed to delete group")
+	}
+	return messageResponse("Group deleted."), nil
+}
+
+func (s *AdminService) AddUserToGroup(ctx context.Context, username, group string) (*models.DataResponse, *models.ErrorResponse) {
+	if err := s.store.AdminAddUserToGroup(ctx, username, group); err != nil {
+		return nil, errorResponse(err, "Failed to add user to group")
+	}
+	return messageResponse("User added to group."), nil
+}
+
+func (s *AdminService) RemoveUserFromGroup(ctx context.Context, username, group string) (*models.DataResponse, *models.ErrorResponse) {
+	if err := s.store.AdminRemoveUserFromGroup(ctx, username, group); err != nil {
+		return nil, errorResponse(err, "Failed to remove user from group")
+	}
+	return messageResponse("User removed from group."), nil
+}
+
+func (s *AdminService) ListUserGroups(ctx context.Context, username string, params *models.ListGroupsParams) (*models.DataResponse, *models.ErrorResponse) {
+	if params.Limit < 0 || params.Limit > maxListUsersLimit {
+		return nil, errorResponse(appError.NewInvalidInputError("limit must be between 1 and 60"), "")
+	}
+
+	res, err := s.store.AdminListGroupsForUser(ctx, username, params)
+	if err != nil {
+		return nil, errorResponse(err, "Failed to list user groups")
+	}
+
+	return models.NewDataResponse(http.StatusOK, res), nil
+}
+
+// revokingAction runs an action that ends the user's sessions and also
+// revokes their access tokens locally, since Cognito only stops honouring
+// them for its own APIs.
+func (s *AdminService) revokingAction(ctx context.Context, username string, action func(context.Context, string) error, failure, message string) (*models.DataResponse, *models.ErrorResponse) {
+	user, err := s.store.AdminGetUser(ctx, username)
+	if err != nil {
+		return nil, errorResponse(err, failure)
+	}
+
+	if err := action(ctx, username); err != nil {
+		return nil, errorResponse(err, failure)
+	}
+
+	now := time.Now()
+	s.revocations.RevokeSubject(user.Attributes["sub"], now.Truncate(time.Second), now.Add(maxAccessTokenLifetime))
+
+	return messageResponse(message), nil
+}
+
+// errorResponse turns a store error into the response sent to the client,
+// using failure for errors that aren't AuthErrors.
+func errorResponse(err error, failure string) *models.ErrorResponse {
+	var authErr *appError.AuthError
+	if errors.As(err, &authErr) {
+		return models.NewAuthErrorResponse(authErr)
+	}
+	return models.NewErrorResponse(http.StatusInternalServerError, failure).WithCause(err)
+}
+
+func messageResponse(message string) *models.DataResponse {
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: message,
+	})
+}
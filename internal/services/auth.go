@@ -1,32 +1,46 @@
 package services
 
 import (
+	"app/internal/config"
 	"app/internal/db"
 	appError "app/internal/errors"
 	"app/internal/models"
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 )
 
 type AuthService struct {
-	store db.AuthStore
+	store          db.AuthStore
+	revocations    db.RevocationList
+	resendThrottle *throttle
+	loginGuard     *loginGuard
 }
 
-func NewAuthService(store db.AuthStore) *AuthService {
+func NewAuthService(store db.AuthStore, revocations db.RevocationList, lockout config.LoginLockout) *AuthService {
 	return &AuthService{
-		store: store,
+		store:          store,
+		revocations:    revocations,
+		resendThrottle: newThrottle(resendCodeCooldown),
+		loginGuard:     newLoginGuard(lockout),
 	}
 }
 
 func (s *AuthService) SignUp(ctx context.Context, user *models.User) (*models.DataResponse, *models.ErrorResponse) {
+	if name, ok := checkMutableAttributes(user.Attributes); !ok {
+		authErr := appError.NewInvalidInputError("Attribute " + name + " cannot be set")
+		return nil, models.NewAuthErrorResponse(authErr)
+	}
+
 	err := s.store.SignUp(ctx, user)
 	if err != nil {
 		var authErr *appError.AuthError
 		if errors.As(err, &authErr) {
-			return nil, models.NewErrorResponse(authErr.StatusCode, authErr.Error())
+			return nil, models.NewAuthErrorResponse(authErr)
 		}
-		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to register user")
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to register user").WithCause(err)
 	}
 
 	return models.NewDataResponse(http.StatusCreated, struct {
@@ -36,14 +50,59 @@ func (s *AuthService) SignUp(ctx context.Context, user *models.User) (*models.Da
 	}), nil
 }
 
+// Login signs the user in unless the account or the client's IP is backing
+// off or locked after failed logins. Only wrong credentials count as failures.
 func (s *AuthService) Login(ctx context.Context, user *models.UserLoginParams) (*models.DataResponse, *models.ErrorResponse) {
+	ip := models.ClientIP(ctx)
+	if res := s.guardLogin(user.Email, ip); res != nil {
+		return nil, res
+	}
+
 	res, err := s.store.Login(ctx, user)
+	switch {
+	case errors.Is(err, appError.ErrInvalidCredentials):
+		s.loginGuard.fail(ctx, user.Email, ip)
+	case err != nil:
+		s.loginGuard.release(user.Email, ip)
+	default:
+		s.loginGuard.succeed(user.Email, ip)
+	}
 	if err != nil {
 		var authErr *appError.AuthError
 		if errors.As(err, &authErr) {
-			return nil, models.NewErrorResponse(authErr.StatusCode, authErr.Error())
+			return nil, models.NewAuthErrorResponse(authErr)
 		}
-		return nil, models.NewErrorResponse(http.StatusInternalServerError, err.Error())
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to log in").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, res), nil
+}
+
+// guardLogin returns the error for a password check of email from ip that
+// has to wait because of earlier failures, or nil if it may go ahead. A check
+// that goes ahead must be settled with the guard's fail, succeed or release.
+func (s *AuthService) guardLogin(email, ip string) *models.ErrorResponse {
+	wait, locked, ok := s.loginGuard.check(email, ip)
+	if ok {
+		return nil
+	}
+	seconds := int(wait.Seconds()) + 1
+	if locked {
+		authErr := appError.NewAccountLockedError(fmt.Sprintf("Please try again in %d seconds", seconds))
+		return models.NewAuthErrorResponse(authErr)
+	}
+	authErr := appError.NewTooManyRequestsError(fmt.Sprintf("Please wait %d seconds before trying again", seconds))
+	return models.NewAuthErrorResponse(authErr)
+}
+
+func (s *AuthService) RespondToChallenge(ctx context.Context, params *models.UserChallengeParams) (*models.DataResponse, *models.ErrorResponse) {
+	res, err := s.store.RespondToChallenge(ctx, params)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to respond to challenge").WithCause(err)
 	}
 
 	return models.NewDataResponse(http.StatusOK, res), nil
@@ -54,9 +113,9 @@ func (s *AuthService) ConfirmAccount(ctx context.Context, user *models.UserConfi
 	if err != nil {
 		var authErr *appError.AuthError
 		if errors.As(err, &authErr) {
-			return nil, models.NewErrorResponse(authErr.StatusCode, authErr.Error())
+			return nil, models.NewAuthErrorResponse(authErr)
 		}
-		return nil, models.NewErrorResponse(http.StatusInternalServerError, err.Error())
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to confirm account").WithCause(err)
 	}
 
 	return models.NewDataResponse(http.StatusOK, struct {
@@ -66,15 +125,149 @@ func (s *AuthService) ConfirmAccount(ctx context.Context, user *models.UserConfi
 	}), nil
 }
 
+func (s *AuthService) ResendConfirmationCode(ctx context.Context, params *models.UserResendCodeParams) (*models.DataResponse, *models.ErrorResponse) {
+	if wait, ok := s.resendThrottle.allow(params.Email); !ok {
+		authErr := appError.NewTooManyRequestsError(fmt.Sprintf("Please wait %d seconds before requesting a new code", int(wait.Seconds())+1))
+		return nil, models.NewAuthErrorResponse(authErr)
+	}
+
+	err := s.store.ResendConfirmationCode(ctx, params)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to resend confirmation code").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: "If the account is awaiting confirmation, a new code has been sent.",
+	}), nil
+}
+
 func (s *AuthService) GetUser(ctx context.Context, token string) (*models.DataResponse, *models.ErrorResponse) {
 	res, err := s.store.GetUser(ctx, token)
 	if err != nil {
 		var authErr *appError.AuthError
 		if errors.As(err, &authErr) {
-			return nil, models.NewErrorResponse(authErr.StatusCode, authErr.Error())
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch user info").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, res), nil
+}
+
+func (s *AuthService) Refresh(ctx context.Context, params *models.UserRefreshParams) (*models.DataResponse, *models.ErrorResponse) {
+	res, err := s.store.Refresh(ctx, params)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
 		}
-		return nil, models.NewErrorResponse(http.StatusInternalServerError, err.Error())
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to refresh session").WithCause(err)
 	}
 
 	return models.NewDataResponse(http.StatusOK, res), nil
 }
+
+func (s *AuthService) ForgotPassword(ctx context.Context, params *models.UserForgotPasswordParams) (*models.DataResponse, *models.ErrorResponse) {
+	err := s.store.ForgotPassword(ctx, params)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to start password reset").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: "If an account exists for this email, a password reset code has been sent.",
+	}), nil
+}
+
+func (s *AuthService) ResetPassword(ctx context.Context, params *models.UserResetPasswordParams) (*models.DataResponse, *models.ErrorResponse) {
+	err := s.store.ConfirmForgotPassword(ctx, params)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to reset password").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: "Password reset successfully.",
+	}), nil
+}
+
+func (s *AuthService) Logout(ctx context.Context, claims *models.Claims, params *models.UserLogoutParams) (*models.DataResponse, *models.ErrorResponse) {
+	if params.RefreshToken == "" {
+		authErr := appError.NewInvalidInputError("Refresh token required")
+		return nil, models.NewAuthErrorResponse(authErr)
+	}
+
+	// Revoke locally first so the access token stops working even if Cognito
+	// can't be reached. origin_jti covers every access token minted from the
+	// same refresh token.
+	expiresAt := tokenExpiry(claims)
+	s.revocations.RevokeToken(claims.Jti, expiresAt)
+	s.revocations.RevokeToken(claims.OriginJti, expiresAt)
+
+	err := s.store.RevokeToken(ctx, params.RefreshToken)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to log out").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: "Logged out successfully.",
+	}), nil
+}
+
+func (s *AuthService) LogoutAll(ctx context.Context, token string, claims *models.Claims) (*models.DataResponse, *models.ErrorResponse) {
+	err := s.store.GlobalSignOut(ctx, token)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to log out").WithCause(err)
+	}
+
+	// Every access token of the user issued so far is now void. None of them
+	// can outlive the lifetime of the current one, so the entry only has to be
+	// kept that long.
+	now := time.Now()
+	lifetime := time.Hour
+	if !claims.IssuedAt.IsZero() {
+		lifetime = tokenExpiry(claims).Sub(claims.IssuedAt)
+	}
+	s.revocations.RevokeSubject(claims.Sub, now.Truncate(time.Second), now.Add(lifetime))
+
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: "Logged out of all sessions.",
+	}), nil
+}
+
+// tokenExpiry returns the exp claim, falling back to an hour from now which is
+// Cognito's default access token lifetime.
+func tokenExpiry(claims *models.Claims) time.Time {
+	if !claims.Exp.IsZero() {
+		return claims.Exp
+	}
+	return time.Now().Add(time.Hour)
+}
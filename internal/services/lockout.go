@@ -0,0 +1,268 @@
+package services
+
+import (
+	"app/internal/config"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// inFlightWait is how long a login is told to wait while another attempt
+	// it would race with is still in flight.
+	inFlightWait = time.Second
+	// attemptTimeout is how long a reserved attempt holds others back if it
+	// is never settled.
+	attemptTimeout = time.Minute
+)
+
+// failures counts the failed logins of one account or IP within a window,
+// and the attempts in flight that may add to them.
+type failures struct {
+	count       int
+	first       time.Time
+	last        time.Time
+	lockedUntil time.Time
+	pending     int
+	reserved    time.Time
+}
+
+// loginGuard tracks failed logins per account and per IP, making callers
+// back off exponentially and locking accounts and IPs that keep failing. It
+// is kept in memory, so each instance of the service guards on its own.
+type loginGuard struct {
+	mu        sync.Mutex
+	policy    config.LoginLockout
+	accounts  map[string]*failures
+	ips       map[string]*failures
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newLoginGuard(policy config.LoginLockout) *loginGuard {
+	return &loginGuard{
+		policy:    policy,
+		accounts:  make(map[string]*failures),
+		ips:       make(map[string]*failures),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// check reports whether a login for email from ip may go ahead and, if it
+// may, reserves the attempt until fail, succeed or release settles it. While
+// reserved, the attempt holds back other logins to the account and counts
+// towards the IP's limit, so a burst of concurrent guesses can't all pass
+// before the first failure is recorded. When the login may not go ahead, the
+// remaining wait is returned along with whether it is because of a lock
+// rather than a backoff.
+func (g *loginGuard) check(email, ip string) (wait time.Duration, locked bool, ok bool) {
+	email = normalizeKey(email)
+	now := g.now()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	account, address := g.lookup(g.accounts, email, now), g.lookup(g.ips, ip, now)
+	for _, f := range []*failures{account, address} {
+		if f == nil {
+			continue
+		}
+		if until := f.lockedUntil.Sub(now); until > 0 {
+			if !locked || until > wait {
+				wait = until
+			}
+			locked = true
+			continue
+		}
+		if locked || f.count == 0 {
+			continue
+		}
+		if until := f.last.Add(g.backoff(f.count)).Sub(now); until > wait {
+			wait = until
+		}
+	}
+	if !locked {
+		inFlight := account != nil && account.pending > 0
+		if address != nil && g.policy.IPMaxFailures > 0 && g.current(address, now)+address.pending >= g.policy.IPMaxFailures {
+			inFlight = true
+		}
+		if inFlight {
+			wait = max(wait, inFlightWait)
+		}
+	}
+	if wait > 0 {
+		return wait, locked, false
+	}
+
+	g.reserve(g.accounts, email, now)
+	if ip != "" {
+		g.reserve(g.ips, ip, now)
+	}
+	return 0, false, true
+}
+
+// fail settles the attempt of email from ip as a failed login, locking
+// either one once it reaches its limit.
+func (g *loginGuard) fail(ctx context.Context, email, ip string) {
+	email = normalizeKey(email)
+	now := g.now()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.settle(g.accounts, email, now)
+	if f := g.record(g.accounts, email, now); g.policy.MaxFailures > 0 && f.count >= g.policy.MaxFailures {
+		count := f.count
+		g.lock(f, now)
+		securityEvent(ctx, "account_locked", "email", email, "ip", ip, "failures", count, "locked_until", f.lockedUntil)
+	}
+	if ip != "" {
+		g.settle(g.ips, ip, now)
+		if f := g.record(g.ips, ip, now); g.policy.IPMaxFailures > 0 && f.count >= g.policy.IPMaxFailures {
+			count := f.count
+			g.lock(f, now)
+			securityEvent(ctx, "ip_locked", "email", email, "ip", ip, "failures", count, "locked_until", f.lockedUntil)
+		}
+	}
+	g.sweep(now)
+}
+
+// succeed settles the attempt of email from ip as a successful login and
+// clears the failures of email. Those of the IP are kept, so an attacker
+// can't reset the IP's count by logging in to an account of their own
+// between guesses.
+func (g *loginGuard) succeed(email, ip string) {
+	email = normalizeKey(email)
+	now := g.now()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.accounts, email)
+	g.settle(g.ips, ip, now)
+}
+
+// release settles the attempt of email from ip without counting it either
+// way, for logins that failed for another reason than the password.
+func (g *loginGuard) release(email, ip string) {
+	email = normalizeKey(email)
+	now := g.now()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.settle(g.accounts, email, now)
+	g.settle(g.ips, ip, now)
+}
+
+// lookup returns the failures of key, or nil if there are none. Attempts
+// reserved longer than attemptTimeout ago are taken to have been abandoned.
+func (g *loginGuard) lookup(entries map[string]*failures, key string, now time.Time) *failures {
+	f, ok := entries[key]
+	if !ok {
+		return nil
+	}
+	if f.pending > 0 && now.Sub(f.reserved) > attemptTimeout {
+		f.pending = 0
+	}
+	return f
+}
+
+// entry returns the failures of key, starting a fresh window once the last
+// one has passed. Attempts in flight carry over to the new window.
+func (g *loginGuard) entry(entries map[string]*failures, key string, now time.Time) *failures {
+	f, ok := entries[key]
+	switch {
+	case !ok:
+		f = &failures{first: now}
+		entries[key] = f
+	case now.Sub(f.first) > g.policy.Window:
+		*f = failures{first: now, pending: f.pending, reserved: f.reserved}
+	}
+	return f
+}
+
+// current is the number of failures within f's window.
+func (g *loginGuard) current(f *failures, now time.Time) int {
+	if now.Sub(f.first) > g.policy.Window {
+		return 0
+	}
+	return f.count
+}
+
+func (g *loginGuard) reserve(entries map[string]*failures, key string, now time.Time) {
+	f := g.entry(entries, key, now)
+	f.pending++
+	f.reserved = now
+}
+
+// settle ends an attempt reserved on key, dropping the entry when nothing is
+// left to remember.
+func (g *loginGuard) settle(entries map[string]*failures, key string, now time.Time) {
+	f := g.lookup(entries, key, now)
+	if f == nil {
+		return
+	}
+	if f.pending > 0 {
+		f.pending--
+	}
+	if f.pending == 0 && f.count == 0 && !now.Before(f.lockedUntil) {
+		delete(entries, key)
+	}
+}
+
+func (g *loginGuard) record(entries map[string]*failures, key string, now time.Time) *failures {
+	f := g.entry(entries, key, now)
+	f.count++
+	f.last = now
+	return f
+}
+
+// lock locks f for the lockout duration and starts a fresh window for when it
+// ends.
+func (g *loginGuard) lock(f *failures, now time.Time) {
+	f.lockedUntil = now.Add(g.policy.LockoutDuration)
+	f.count = 0
+	f.first = f.lockedUntil
+}
+
+// backoff is the wait after count failures: the base wait doubled for every
+// failure after the first, capped at the maximum.
+func (g *loginGuard) backoff(count int) time.Duration {
+	wait := g.policy.BackoffBase
+	for i := 1; i < count && wait < g.policy.BackoffMax; i++ {
+		wait *= 2
+	}
+	return min(wait, g.policy.BackoffMax)
+}
+
+// sweep drops entries that are neither locked, within their window nor
+// holding an attempt in flight so the maps don't grow with every address
+// that ever failed a login. It runs at most once a minute so a burst of
+// failures doesn't walk both maps each time.
+func (g *loginGuard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < time.Minute {
+		return
+	}
+	g.lastSweep = now
+	for _, entries := range []map[string]*failures{g.accounts, g.ips} {
+		for key, f := range entries {
+			if g.lookup(entries, key, now).pending == 0 && now.After(f.lockedUntil) && now.Sub(f.first) > g.policy.Window {
+				delete(entries, key)
+			}
+		}
+	}
+}
+
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.TrimSpace(key))
+}
+
+// securityEvent logs an event worth alerting on, such as a lockout, under a
+// fixed message so it can be filtered out of the other logs.
+func securityEvent(ctx context.Context, event string, args ...any) {
+	slog.WarnContext(ctx, "security event", append([]any{"event", event}, args...)...)
+}
@@ -0,0 +1,453 @@
+package services
+
+import (
+	"app/internal/config"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testLockout = config.LoginLockout{
+	MaxFailures:     5,
+	IPMaxFailures:   8,
+	Window:          10 * time.Minute,
+	LockoutDuration: 15 * time.Minute,
+	BackoffBase:     time.Second,
+	BackoffMax:      4 * time.Second,
+}
+
+// newTestLoginGuard returns a guard whose clock only moves when the returned
+// function advances it.
+func newTestLoginGuard(policy config.LoginLockout) (*loginGuard, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := newLoginGuard(policy)
+	g.now = func() time.Time { return now }
+	g.lastSweep = now
+	return g, func(d time.Duration) { now = now.Add(d) }
+}
+
+// eventRecorder is a slog.Handler that keeps the security events logged.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []map[string]slog.Value
+}
+
+func (e *eventRecorder) Enabled(context.Context, slog.Level) bool { return true }
+func (e *eventRecorder) WithAttrs([]slog.Attr) slog.Handler       { return e }
+func (e *eventRecorder) WithGroup(string) slog.Handler            { return e }
+
+func (e *eventRecorder) Handle(_ context.Context, record slog.Record) error {
+	if record.Message != "security event" {
+		return nil
+	}
+	attrs := make(map[string]slog.Value)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs[attr.Key] = attr.Value
+		return true
+	})
+	e.mu.Lock()
+	e.events = append(e.events, attrs)
+	e.mu.Unlock()
+	return nil
+}
+
+func recordEvents(t *testing.T) *eventRecorder {
+	t.Helper()
+	events := &eventRecorder{}
+	logger := slog.Default()
+	slog.SetDefault(slog.New(events))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+	return events
+}
+
+func TestLoginGuardBackoff(t *testing.T) {
+	g := newLoginGuard(testLockout)
+
+	tests := []struct {
+		count int
+		want  time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 4 * time.Second},
+		{100, 4 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := g.backoff(tt.count); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestLoginGuardCheck(t *testing.T) {
+	const (
+		email = "jane@example.com"
+		ip    = "192.0.2.1"
+	)
+
+	tests := []struct {
+		name       string
+		setup      func(g *loginGuard, advance func(time.Duration))
+		email      string
+		ip         string
+		wantWait   time.Duration
+		wantLocked bool
+		wantOK     bool
+	}{
+		{
+			name:   "no failures",
+			setup:  func(*loginGuard, func(time.Duration)) {},
+			email:  email,
+			ip:     ip,
+			wantOK: true,
+		},
+		{
+			name: "backoff after a failure",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				g.fail(context.Background(), email, ip)
+			},
+			email:    email,
+			ip:       ip,
+			wantWait: time.Second,
+		},
+		{
+			name: "backoff doubles",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				g.fail(context.Background(), email, ip)
+				g.fail(context.Background(), email, ip)
+			},
+			email:    email,
+			ip:       ip,
+			wantWait: 2 * time.Second,
+		},
+		{
+			name: "backoff is capped",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				for range 4 {
+					g.fail(context.Background(), email, ip)
+				}
+			},
+			email:    email,
+			ip:       ip,
+			wantWait: 4 * time.Second,
+		},
+		{
+			name: "backoff elapsed",
+			setup: func(g *loginGuard, advance func(time.Duration)) {
+				g.fail(context.Background(), email, ip)
+				advance(time.Second)
+			},
+			email:  email,
+			ip:     ip,
+			wantOK: true,
+		},
+		{
+			name: "emails are normalised",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				g.fail(context.Background(), " Jane@Example.com", "")
+			},
+			email:    email,
+			ip:       "192.0.2.2",
+			wantWait: time.Second,
+		},
+		{
+			name: "account locked at the maximum",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				for range testLockout.MaxFailures {
+					g.fail(context.Background(), email, ip)
+				}
+			},
+			email:      email,
+			ip:         "192.0.2.2",
+			wantWait:   testLockout.LockoutDuration,
+			wantLocked: true,
+		},
+		{
+			name: "lock expires",
+			setup: func(g *loginGuard, advance func(time.Duration)) {
+				for range testLockout.MaxFailures {
+					g.fail(context.Background(), email, ip)
+				}
+				advance(testLockout.LockoutDuration)
+			},
+			email:  email,
+			ip:     "192.0.2.2",
+			wantOK: true,
+		},
+		{
+			name: "IP locked at the maximum",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				for i := range testLockout.IPMaxFailures {
+					g.fail(context.Background(), string(rune('a'+i))+"@example.com", ip)
+				}
+			},
+			email:      email,
+			ip:         ip,
+			wantWait:   testLockout.LockoutDuration,
+			wantLocked: true,
+		},
+		{
+			name: "failures outside the window are forgotten",
+			setup: func(g *loginGuard, advance func(time.Duration)) {
+				for range testLockout.MaxFailures - 1 {
+					g.fail(context.Background(), email, ip)
+				}
+				advance(testLockout.Window + time.Second)
+				g.fail(context.Background(), email, ip)
+			},
+			email:    email,
+			ip:       ip,
+			wantWait: time.Second,
+		},
+		{
+			name: "success clears the account",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				g.fail(context.Background(), email, ip)
+				g.succeed(email, ip)
+			},
+			email:  email,
+			ip:     "192.0.2.2",
+			wantOK: true,
+		},
+		{
+			name: "success keeps the IP's failures",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				g.fail(context.Background(), email, ip)
+				g.succeed(email, ip)
+			},
+			email:    "john@example.com",
+			ip:       ip,
+			wantWait: time.Second,
+		},
+		{
+			name: "attempt in flight holds back the account",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				g.check(email, ip)
+			},
+			email:    email,
+			ip:       "192.0.2.2",
+			wantWait: inFlightWait,
+		},
+		{
+			name: "attempt in flight leaves other accounts alone",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				g.check(email, ip)
+			},
+			email:  "john@example.com",
+			ip:     ip,
+			wantOK: true,
+		},
+		{
+			name: "attempts in flight count towards the IP limit",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				for i := range testLockout.IPMaxFailures {
+					g.check(string(rune('a'+i))+"@example.com", ip)
+				}
+			},
+			email:    email,
+			ip:       ip,
+			wantWait: inFlightWait,
+		},
+		{
+			name: "released attempt frees the account",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				g.check(email, ip)
+				g.release(email, ip)
+			},
+			email:  email,
+			ip:     ip,
+			wantOK: true,
+		},
+		{
+			name: "successful attempts free the IP",
+			setup: func(g *loginGuard, _ func(time.Duration)) {
+				for i := range testLockout.IPMaxFailures {
+					g.check(string(rune('a'+i))+"@example.com", ip)
+					g.succeed(string(rune('a'+i))+"@example.com", ip)
+				}
+			},
+			email:  email,
+			ip:     ip,
+			wantOK: true,
+		},
+		{
+			name: "abandoned attempt expires",
+			setup: func(g *loginGuard, advance func(time.Duration)) {
+				g.check(email, ip)
+				advance(attemptTimeout + time.Second)
+			},
+			email:  email,
+			ip:     ip,
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recordEvents(t)
+			g, advance := newTestLoginGuard(testLockout)
+			tt.setup(g, advance)
+
+			wait, locked, ok := g.check(tt.email, tt.ip)
+			if wait != tt.wantWait || locked != tt.wantLocked || ok != tt.wantOK {
+				t.Errorf("check = %v, %v, %v, want %v, %v, %v", wait, locked, ok, tt.wantWait, tt.wantLocked, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLoginGuardConcurrentAttempts(t *testing.T) {
+	const (
+		attempts = 50
+		ip       = "192.0.2.1"
+	)
+
+	tests := []struct {
+		name   string
+		emails func(i int) string
+		want   int
+	}{
+		{
+			name:   "one account",
+			emails: func(int) string { return "jane@example.com" },
+			want:   1,
+		},
+		{
+			name:   "many accounts from one IP",
+			emails: func(i int) string { return string(rune('a'+i%26)) + string(rune('a'+i/26)) + "@example.com" },
+			want:   testLockout.IPMaxFailures,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recordEvents(t)
+			g, _ := newTestLoginGuard(testLockout)
+
+			// Every attempt is checked before any of them fails, as when a
+			// client fires its guesses at once.
+			var (
+				wg      sync.WaitGroup
+				start   = make(chan struct{})
+				mu      sync.Mutex
+				allowed []string
+			)
+			for i := range attempts {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					if _, _, ok := g.check(tt.emails(i), ip); ok {
+						mu.Lock()
+						allowed = append(allowed, tt.emails(i))
+						mu.Unlock()
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			if len(allowed) != tt.want {
+				t.Fatalf("allowed %d attempts, want %d", len(allowed), tt.want)
+			}
+			for _, email := range allowed {
+				g.fail(context.Background(), email, ip)
+			}
+			if _, _, ok := g.check(tt.emails(0), ip); ok {
+				t.Errorf("check after the failures was allowed")
+			}
+		})
+	}
+}
+
+func TestLoginGuardSecurityEvents(t *testing.T) {
+	const ip = "192.0.2.1"
+
+	tests := []struct {
+		name     string
+		failures int
+		emails   func(i int) string
+		want     []string
+	}{
+		{
+			name:     "below the limits",
+			failures: testLockout.MaxFailures - 1,
+			emails:   func(int) string { return "jane@example.com" },
+		},
+		{
+			name:     "account locked",
+			failures: testLockout.MaxFailures,
+			emails:   func(int) string { return "jane@example.com" },
+			want:     []string{"account_locked"},
+		},
+		{
+			name:     "IP locked",
+			failures: testLockout.IPMaxFailures,
+			emails:   func(i int) string { return string(rune('a'+i)) + "@example.com" },
+			want:     []string{"ip_locked"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := recordEvents(t)
+			g, _ := newTestLoginGuard(testLockout)
+			for i := range tt.failures {
+				g.fail(context.Background(), tt.emails(i), ip)
+			}
+
+			if len(events.events) != len(tt.want) {
+				t.Fatalf("events = %v, want %v", events.events, tt.want)
+			}
+			for i, event := range events.events {
+				if got := event["event"].String(); got != tt.want[i] {
+					t.Errorf("event = %s, want %s", got, tt.want[i])
+				}
+				if got := event["failures"].Int64(); got != int64(tt.failures) {
+					t.Errorf("failures = %d, want %d", got, tt.failures)
+				}
+				if got := event["ip"].String(); got != ip {
+					t.Errorf("ip = %s, want %s", got, ip)
+				}
+				if _, ok := event["locked_until"]; !ok {
+					t.Errorf("locked_until missing from %v", event)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginGuardSweep(t *testing.T) {
+	recordEvents(t)
+	g, advance := newTestLoginGuard(testLockout)
+	ctx := context.Background()
+
+	g.fail(ctx, "jane@example.com", "192.0.2.1")
+	for range testLockout.MaxFailures {
+		g.fail(ctx, "john@example.com", "192.0.2.2")
+	}
+
+	advance(30 * time.Second)
+	g.fail(ctx, "anne@example.com", "192.0.2.3")
+	if len(g.accounts) != 3 {
+		t.Fatalf("accounts = %d, want 3 before a minute has passed", len(g.accounts))
+	}
+
+	advance(testLockout.Window)
+	g.fail(ctx, "anne@example.com", "192.0.2.3")
+	if _, ok := g.accounts["jane@example.com"]; ok {
+		t.Errorf("expired account was not swept")
+	}
+	if _, ok := g.ips["192.0.2.1"]; ok {
+		t.Errorf("expired IP was not swept")
+	}
+	for _, key := range []string{"john@example.com", "anne@example.com"} {
+		if _, ok := g.accounts[key]; !ok {
+			t.Errorf("account %s was swept while locked or within its window", key)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"errors"
+	"net/http"
+)
+
+func (s *AuthService) StartMFAEnrollment(ctx context.Context, token string, claims *models.Claims) (*models.DataResponse, *models.ErrorResponse) {
+	res, err := s.store.AssociateSoftwareToken(ctx, token, claims.Username)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to start MFA enrollment").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, res), nil
+}
+
+// VerifyMFAEnrollment checks the first code from the authenticator app and,
+// once it matches, turns TOTP on as the preferred method so the next login
+// asks for it.
+func (s *AuthService) VerifyMFAEnrollment(ctx context.Context, token string, params *models.MFAVerifyParams) (*models.DataResponse, *models.ErrorResponse) {
+	err := s.store.VerifySoftwareToken(ctx, token, params)
+	if err == nil {
+		err = s.store.SetMFAPreference(ctx, token, &models.MFAPreferenceParams{
+			TOTP: &models.MFASettings{Enabled: true, Preferred: true},
+		})
+	}
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to verify MFA code").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: "TOTP MFA enabled.",
+	}), nil
+}
+
+func (s *AuthService) SetMFAPreference(ctx context.Context, token string, params *models.MFAPreferenceParams) (*models.DataResponse, *models.ErrorResponse) {
+	err := s.store.SetMFAPreference(ctx, token, params)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to update MFA preference").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: "MFA preference updated.",
+	}), nil
+}
+
+func (s *AuthService) DisableMFA(ctx context.Context, token string) (*models.DataResponse, *models.ErrorResponse) {
+	err := s.store.SetMFAPreference(ctx, token, &models.MFAPreferenceParams{
+		TOTP: &models.MFASettings{},
+		SMS:  &models.MFASettings{},
+	})
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to disable MFA").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: "MFA disabled.",
+	}), nil
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	appError "app/internal/errors"
+	"app/internal/models"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// mutableAttributes are the attributes users may set on their own account.
+// Everything else, such as sub, the *_verified flags and custom attributes,
+// is managed by Cognito or by administrators.
+var mutableAttributes = map[string]bool{
+	"name":               true,
+	"given_name":         true,
+	"family_name":        true,
+	"middle_name":        true,
+	"nickname":           true,
+	"preferred_username": true,
+	"email":              true,
+	"phone_number":       true,
+	"birthdate":          true,
+	"gender":             true,
+	"locale":             true,
+	"zoneinfo":           true,
+	"picture":            true,
+	"profile":            true,
+	"website":            true,
+}
+
+// verifiableAttributes are the attributes Cognito sends a verification code
+// for.
+var verifiableAttributes = map[string]bool{
+	"email":        true,
+	"phone_number": true,
+}
+
+func (s *AuthService) UpdateUser(ctx context.Context, token string, params *models.UserUpdateParams) (*models.DataResponse, *models.ErrorResponse) {
+	if len(params.Attributes) == 0 {
+		authErr := appError.NewInvalidInputError("No attributes to update")
+		return nil, models.NewAuthErrorResponse(authErr)
+	}
+	if name, ok := checkMutableAttributes(params.Attributes); !ok {
+		authErr := appError.NewInvalidInputError("Attribute " + name + " cannot be changed")
+		return nil, models.NewAuthErrorResponse(authErr)
+	}
+
+	deliveries, err := s.store.UpdateUserAttributes(ctx, token, params.Attributes)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to update user").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, &models.UserUpdateResponse{
+		Message:       "User updated.",
+		Verifications: deliveries,
+	}), nil
+}
+
+func (s *AuthService) SendAttributeVerification(ctx context.Context, token string, params *models.UserVerifyAttributeParams) (*models.DataResponse, *models.ErrorResponse) {
+	if !verifiableAttributes[params.Attribute] {
+		authErr := appError.NewInvalidInputError("attribute must be email or phone_number")
+		return nil, models.NewAuthErrorResponse(authErr)
+	}
+
+	res, err := s.store.SendAttributeVerificationCode(ctx, token, params.Attribute)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to send verification code").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, res), nil
+}
+
+func (s *AuthService) ConfirmAttribute(ctx context.Context, token string, params *models.UserConfirmAttributeParams) (*models.DataResponse, *models.ErrorResponse) {
+	if !verifiableAttributes[params.Attribute] {
+		authErr := appError.NewInvalidInputError("attribute must be email or phone_number")
+		return nil, models.NewAuthErrorResponse(authErr)
+	}
+
+	err := s.store.VerifyUserAttribute(ctx, token, params)
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to verify attribute").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: "Attribute verified.",
+	}), nil
+}
+
+// ChangePassword changes the signed-in user's password. Wrong current
+// passwords count as failed logins, so a stolen token can't be used to guess
+// the password faster than logging in would.
+func (s *AuthService) ChangePassword(ctx context.Context, token string, claims *models.Claims, params *models.UserChangePasswordParams) (*models.DataResponse, *models.ErrorResponse) {
+	if params.CurrentPassword == "" || params.NewPassword == "" {
+		authErr := appError.NewInvalidInputError("Current and new password required")
+		return nil, models.NewAuthErrorResponse(authErr)
+	}
+
+	account, ip := guardAccount(claims), models.ClientIP(ctx)
+	if res := s.guardLogin(account, ip); res != nil {
+		return nil, res
+	}
+
+	err := s.store.ChangePassword(ctx, token, params)
+	switch {
+	case errors.Is(err, appError.ErrIncorrectPassword):
+		s.loginGuard.fail(ctx, account, ip)
+	case err != nil:
+		s.loginGuard.release(account, ip)
+	default:
+		s.loginGuard.succeed(account, ip)
+	}
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to change password").WithCause(err)
+	}
+
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: "Password changed.",
+	}), nil
+}
+
+// DeleteUser deletes the signed-in user's account once their password has
+// been checked. Cognito only needs the access token, so the password is
+// checked by signing in with it first, behind the same guard as logins.
+func (s *AuthService) DeleteUser(ctx context.Context, token string, claims *models.Claims, params *models.UserDeleteParams) (*models.DataResponse, *models.ErrorResponse) {
+	if params.Password == "" {
+		authErr := appError.NewInvalidInputError("Password required")
+		return nil, models.NewAuthErrorResponse(authErr)
+	}
+
+	account, ip := guardAccount(claims), models.ClientIP(ctx)
+	if res := s.guardLogin(account, ip); res != nil {
+		return nil, res
+	}
+
+	res, err := s.store.Login(ctx, &models.UserLoginParams{Email: claims.Username, Password: params.Password})
+	switch {
+	case errors.Is(err, appError.ErrInvalidCredentials):
+		s.loginGuard.fail(ctx, account, ip)
+		err = appError.NewIncorrectPasswordError()
+	case err != nil:
+		s.loginGuard.release(account, ip)
+	default:
+		s.loginGuard.succeed(account, ip)
+	}
+	if err == nil {
+		// A challenge means the password was accepted. The session opened to
+		// check it is dropped right away.
+		if res.RefreshToken != "" {
+			_ = s.store.RevokeToken(ctx, res.RefreshToken)
+		}
+		err = s.store.DeleteUser(ctx, token)
+	}
+	if err != nil {
+		var authErr *appError.AuthError
+		if errors.As(err, &authErr) {
+			return nil, models.NewAuthErrorResponse(authErr)
+		}
+		return nil, models.NewErrorResponse(http.StatusInternalServerError, "Failed to delete user").WithCause(err)
+	}
+
+	now := time.Now()
+	s.revocations.RevokeSubject(claims.Sub, now.Truncate(time.Second), now.Add(maxAccessTokenLifetime))
+
+	return models.NewDataResponse(http.StatusOK, struct {
+		Message string `json:"message"`
+	}{
+		Message: "Account deleted.",
+	}), nil
+}
+
+// guardAccount is the key the login guard counts a signed-in user's password
+// failures under: their email, as for logins, when the token carries it and
+// their username otherwise.
+func guardAccount(claims *models.Claims) string {
+	if claims.Email != "" {
+		return claims.Email
+	}
+	return claims.Username
+}
+
+// checkMutableAttributes reports the first attribute users may not set
+// themselves.
+func checkMutableAttributes(attributes map[string]string) (string, bool) {
+	for name := range attributes {
+		if !mutableAttributes[name] {
+			return name, false
+		}
+	}
+	return "", true
+}
@@ -8,6 +8,39 @@ import (
 type AuthServiceInterface interface {
 	SignUp(ctx context.Context, user *models.User) (*models.DataResponse, *models.ErrorResponse)
 	Login(ctx context.Context, user *models.UserLoginParams) (*models.DataResponse, *models.ErrorResponse)
+	RespondToChallenge(ctx context.Context, params *models.UserChallengeParams) (*models.DataResponse, *models.ErrorResponse)
 	ConfirmAccount(ctx context.Context, user *models.UserConfirmationParams) (*models.DataResponse, *models.ErrorResponse)
+	ResendConfirmationCode(ctx context.Context, params *models.UserResendCodeParams) (*models.DataResponse, *models.ErrorResponse)
 	GetUser(ctx context.Context, token string) (*models.DataResponse, *models.ErrorResponse)
+	UpdateUser(ctx context.Context, token string, params *models.UserUpdateParams) (*models.DataResponse, *models.ErrorResponse)
+	SendAttributeVerification(ctx context.Context, token string, params *models.UserVerifyAttributeParams) (*models.DataResponse, *models.ErrorResponse)
+	ConfirmAttribute(ctx context.Context, token string, params *models.UserConfirmAttributeParams) (*models.DataResponse, *models.ErrorResponse)
+	ChangePassword(ctx context.Context, token string, claims *models.Claims, params *models.UserChangePasswordParams) (*models.DataResponse, *models.ErrorResponse)
+	DeleteUser(ctx context.Context, token string, claims *models.Claims, params *models.UserDeleteParams) (*models.DataResponse, *models.ErrorResponse)
+	Refresh(ctx context.Context, params *models.UserRefreshParams) (*models.DataResponse, *models.ErrorResponse)
+	Logout(ctx context.Context, claims *models.Claims, params *models.UserLogoutParams) (*models.DataResponse, *models.ErrorResponse)
+	LogoutAll(ctx context.Context, token string, claims *models.Claims) (*models.DataResponse, *models.ErrorResponse)
+	StartMFAEnrollment(ctx context.Context, token string, claims *models.Claims) (*models.DataResponse, *models.ErrorResponse)
+	VerifyMFAEnrollment(ctx context.Context, token string, params *models.MFAVerifyParams) (*models.DataResponse, *models.ErrorResponse)
+	SetMFAPreference(ctx context.Context, token string, params *models.MFAPreferenceParams) (*models.DataResponse, *models.ErrorResponse)
+	DisableMFA(ctx context.Context, token string) (*models.DataResponse, *models.ErrorResponse)
+	ForgotPassword(ctx context.Context, params *models.UserForgotPasswordParams) (*models.DataResponse, *models.ErrorResponse)
+	ResetPassword(ctx context.Context, params *models.UserResetPasswordParams) (*models.DataResponse, *models.ErrorResponse)
+}
+
+type AdminServiceInterface interface {
+	ListUsers(ctx context.Context, params *models.ListUsersParams) (*models.DataResponse, *models.ErrorResponse)
+	GetUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse)
+	DisableUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse)
+	EnableUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse)
+	DeleteUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse)
+	ConfirmUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse)
+	ResetUserPassword(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse)
+	SignOutUser(ctx context.Context, username string) (*models.DataResponse, *models.ErrorResponse)
+	ListGroups(ctx context.Context, params *models.ListGroupsParams) (*models.DataResponse, *models.ErrorResponse)
+	CreateGroup(ctx context.Context, params *models.CreateGroupParams) (*models.DataResponse, *models.ErrorResponse)
+	DeleteGroup(ctx context.Context, name string) (*models.DataResponse, *models.ErrorResponse)
+	AddUserToGroup(ctx context.Context, username, group string) (*models.DataResponse, *models.ErrorResponse)
+	RemoveUserFromGroup(ctx context.Context, username, group string) (*models.DataResponse, *models.ErrorResponse)
+	ListUserGroups(ctx context.Context, username string, params *models.ListGroupsParams) (*models.DataResponse, *models.ErrorResponse)
 }
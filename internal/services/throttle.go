@@ -0,0 +1,60 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const resendCodeCooldown = time.Minute
+
+// throttle allows one action per key every cooldown. It is kept in memory,
+// so each instance of the service throttles on its own.
+type throttle struct {
+	mu        sync.Mutex
+	cooldown  time.Duration
+	last      map[string]time.Time
+	lastSweep time.Time
+}
+
+func newThrottle(cooldown time.Duration) *throttle {
+	return &throttle{
+		cooldown:  cooldown,
+		last:      make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow records an attempt for key and reports whether it may go ahead. When
+// it may not, the remaining wait is returned.
+func (t *throttle) allow(key string) (time.Duration, bool) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last, ok := t.last[key]; ok {
+		if wait := t.cooldown - now.Sub(last); wait > 0 {
+			return wait, false
+		}
+	}
+	t.last[key] = now
+	t.sweep(now)
+	return 0, true
+}
+
+// sweep drops keys whose cooldown has passed so the map doesn't grow with
+// every address that ever asked for a code. It runs at most once a minute so
+// a busy throttle doesn't walk the whole map on every attempt.
+func (t *throttle) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < time.Minute {
+		return
+	}
+	t.lastSweep = now
+	for key, last := range t.last {
+		if now.Sub(last) >= t.cooldown {
+			delete(t.last, key)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package validation
+
+import (
+	"app/internal/config"
+	"app/internal/models"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// passwordSymbols are the characters Cognito counts as symbols in a password,
+// the space included.
+const passwordSymbols = "^$*.[]{}()?\"!@#%&/\\,><':;|_~`=+- "
+
+// Validator checks request payloads against the rules in the validate tags
+// of their fields. Rules are separated by commas and run in order, stopping
+// at the first one a field fails:
+//
+//	required   the field must not be empty
+//	omitempty  skip the other rules when the field is empty
+//	max=N      at most N characters, or N entries for a map
+//	email      a bare email address such as jane@example.com
+//	password   the password satisfies the pool's password policy
+//	code       a six digit confirmation or TOTP code
+type Validator struct {
+	policy config.PasswordPolicy
+}
+
+func New(policy config.PasswordPolicy) *Validator {
+	return &Validator{
+		policy: policy,
+	}
+}
+
+// Validate returns the fields of payload, a pointer to a struct, that break
+// their rules. It returns nil when every field is valid.
+func (v *Validator) Validate(payload any) []models.FieldError {
+	value := reflect.Indirect(reflect.ValueOf(payload))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fieldErrors []models.FieldError
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		rules, ok := field.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+
+		if message := v.check(value.Field(i), rules); message != "" {
+			fieldErrors = append(fieldErrors, models.FieldError{
+				Field:   fieldName(field),
+				Message: message,
+			})
+		}
+	}
+	return fieldErrors
+}
+
+// check returns why value breaks rules, or "" when it doesn't.
+func (v *Validator) check(value reflect.Value, rules string) string {
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if isEmpty(value) {
+				return "is required"
+			}
+		case "omitempty":
+			if isEmpty(value) {
+				return ""
+			}
+		case "max":
+			limit, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("validation: invalid max rule %q", rule))
+			}
+			if value.Kind() == reflect.Map {
+				if value.Len() > limit {
+					return fmt.Sprintf("must have at most %d entries", limit)
+				}
+			} else if utf8.RuneCountInString(value.String()) > limit {
+				return fmt.Sprintf("must be at most %d characters", limit)
+			}
+		case "email":
+			if !isEmail(value.String()) {
+				return "must be a valid email address"
+			}
+		case "password":
+			if message := v.checkPassword(value.String()); message != "" {
+				return message
+			}
+		case "code":
+			if !isCode(value.String()) {
+				return "must be a 6 digit code"
+			}
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %q", rule))
+		}
+	}
+	return ""
+}
+
+func (v *Validator) checkPassword(password string) string {
+	if utf8.RuneCountInString(password) < v.policy.MinLength {
+		return fmt.Sprintf("must be at least %d characters", v.policy.MinLength)
+	}
+
+	// Cognito only counts Basic Latin letters and digits, so accented
+	// letters and other scripts' digits don't satisfy the policy.
+	var upper, lower, number, symbol bool
+	for _, r := range password {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			number = true
+		case strings.ContainsRune(passwordSymbols, r):
+			symbol = true
+		}
+	}
+
+	switch {
+	case v.policy.RequireUppercase && !upper:
+		return "must contain an uppercase letter"
+	case v.policy.RequireLowercase && !lower:
+		return "must contain a lowercase letter"
+	case v.policy.RequireNumbers && !number:
+		return "must contain a number"
+	case v.policy.RequireSymbols && !symbol:
+		return "must contain a symbol"
+	}
+	return ""
+}
+
+// isEmpty reports whether value is its zero value or an empty map.
+func isEmpty(value reflect.Value) bool {
+	return value.IsZero() || (value.Kind() == reflect.Map && value.Len() == 0)
+}
+
+func isEmail(value string) bool {
+	address, err := mail.ParseAddress(value)
+	if err != nil || address.Address != value {
+		return false
+	}
+	_, domain, _ := strings.Cut(value, "@")
+	return strings.Contains(domain, ".")
+}
+
+func isCode(value string) bool {
+	if len(value) != 6 {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldName is the name field has in JSON.
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,125 @@
+package validation
+
+import (
+	"app/internal/config"
+	"app/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	strict := config.PasswordPolicy{
+		MinLength:        8,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireNumbers:   true,
+		RequireSymbols:   true,
+	}
+
+	tests := []struct {
+		name   string
+		policy config.PasswordPolicy
+		rules  string
+		value  any
+		want   string
+	}{
+		{name: "required string", rules: "required", value: "jane", want: ""},
+		{name: "required empty string", rules: "required", value: "", want: "is required"},
+		{name: "required nil map", rules: "required", value: map[string]string(nil), want: "is required"},
+		{name: "required empty map", rules: "required", value: map[string]string{}, want: "is required"},
+		{name: "required map", rules: "required", value: map[string]string{"a": "b"}, want: ""},
+
+		{name: "omitempty skips empty string", rules: "omitempty,code", value: "", want: ""},
+		{name: "omitempty skips empty map", rules: "omitempty,required", value: map[string]string{}, want: ""},
+		{name: "omitempty checks set value", rules: "omitempty,code", value: "12345", want: "must be a 6 digit code"},
+
+		{name: "max counts runes", rules: "max=4", value: "éééé", want: ""},
+		{name: "max over in runes", rules: "max=4", value: "ééééé", want: "must be at most 4 characters"},
+		{name: "max counts map entries", rules: "max=1", value: map[string]string{"a": "long value"}, want: ""},
+		{name: "max over in map entries", rules: "max=1", value: map[string]string{"a": "", "b": ""}, want: "must have at most 1 entries"},
+
+		{name: "email", rules: "email", value: "jane@example.com", want: ""},
+		{name: "email with plus", rules: "email", value: "jane+test@mail.example.com", want: ""},
+		{name: "email display name", rules: "email", value: "Jane <jane@example.com>", want: "must be a valid email address"},
+		{name: "email angle brackets", rules: "email", value: "<jane@example.com>", want: "must be a valid email address"},
+		{name: "email no dot in domain", rules: "email", value: "jane@localhost", want: "must be a valid email address"},
+		{name: "email trailing dot", rules: "email", value: "jane@example.", want: "must be a valid email address"},
+		{name: "email no at", rules: "email", value: "jane.example.com", want: "must be a valid email address"},
+		{name: "email surrounding space", rules: "email", value: " jane@example.com ", want: "must be a valid email address"},
+		{name: "email empty", rules: "email", value: "", want: "must be a valid email address"},
+
+		{name: "password meets strict policy", policy: strict, rules: "password", value: "Passw0rd!", want: ""},
+		{name: "password too short", policy: strict, rules: "password", value: "Pa0!", want: "must be at least 8 characters"},
+		{name: "password length in runes", policy: config.PasswordPolicy{MinLength: 4}, rules: "password", value: "ééé", want: "must be at least 4 characters"},
+		{name: "password without uppercase", policy: strict, rules: "password", value: "passw0rd!", want: "must contain an uppercase letter"},
+		{name: "password with non-ASCII uppercase", policy: strict, rules: "password", value: "pässw0rd!Ä", want: "must contain an uppercase letter"},
+		{name: "password without lowercase", policy: strict, rules: "password", value: "PASSW0RD!", want: "must contain a lowercase letter"},
+		{name: "password without number", policy: strict, rules: "password", value: "Password!", want: "must contain a number"},
+		{name: "password with non-ASCII digit", policy: strict, rules: "password", value: "Passw٣rd!", want: "must contain a number"},
+		{name: "password without symbol", policy: strict, rules: "password", value: "Passw0rds", want: "must contain a symbol"},
+		{name: "password space is a symbol", policy: strict, rules: "password", value: "Abcdefg1 x", want: ""},
+		{name: "password tab is not a symbol", policy: strict, rules: "password", value: "Abcdefg1\tx", want: "must contain a symbol"},
+		{name: "password with no requirements", policy: config.PasswordPolicy{}, rules: "password", value: "x", want: ""},
+
+		{name: "code", rules: "code", value: "012345", want: ""},
+		{name: "code too short", rules: "code", value: "12345", want: "must be a 6 digit code"},
+		{name: "code too long", rules: "code", value: "1234567", want: "must be a 6 digit code"},
+		{name: "code with letter", rules: "code", value: "12345a", want: "must be a 6 digit code"},
+		{name: "code with non-ASCII digits", rules: "code", value: "١٢٣٤٥٦", want: "must be a 6 digit code"},
+		{name: "code with full-width digit", rules: "code", value: "12345６", want: "must be a 6 digit code"},
+
+		{name: "rules stop at the first failure", rules: "required,max=2,email", value: "", want: "is required"},
+		{name: "rules run in order", rules: "max=2,email", value: "jane@example.com", want: "must be at most 2 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(tt.policy).check(reflect.ValueOf(tt.value), tt.rules)
+			if got != tt.want {
+				t.Errorf("check(%q, %q) = %q, want %q", tt.value, tt.rules, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	v := New(config.PasswordPolicy{MinLength: 8})
+
+	tests := []struct {
+		name    string
+		payload any
+		want    []models.FieldError
+	}{
+		{
+			name:    "valid sign up",
+			payload: &models.User{Name: "Jane", Email: "jane@example.com", Password: "password"},
+		},
+		{
+			name:    "fields are named as in JSON",
+			payload: &models.User{Name: strings.Repeat("a", 257), Email: "jane", Password: "short"},
+			want: []models.FieldError{
+				{Field: "name", Message: "must be at most 256 characters"},
+				{Field: "email", Message: "must be a valid email address"},
+				{Field: "password", Message: "must be at least 8 characters"},
+			},
+		},
+		{
+			name:    "optional fields",
+			payload: &models.UserChallengeParams{Username: "jane", ChallengeName: "SOFTWARE_TOKEN_MFA", Session: "session"},
+		},
+		{
+			name:    "not a struct",
+			payload: "jane",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := v.Validate(tt.payload)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}